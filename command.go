@@ -808,7 +808,16 @@ func setupTestNetCmd(c *cli.Context) error {
 			},
 		},
 	}
-	genesisData, err := json.MarshalIndent(genesis, "", "  ")
+	genesisData, err := json.Marshal(genesis)
+	if err != nil {
+		return err
+	}
+	var gns kernel.Genesis
+	err = json.Unmarshal(genesisData, &gns)
+	if err != nil {
+		return err
+	}
+	genesisData, err = json.MarshalIndent(kernel.SortGenesisNodes(&gns), "", "  ")
 	if err != nil {
 		return err
 	}
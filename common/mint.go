@@ -11,6 +11,13 @@ const (
 	mintGroupKernelNodeLegacy = "KERNELNODE"
 )
 
+type MintGroup string
+
+const (
+	MintGroupUniversal        MintGroup = mintGroupUniversal
+	MintGroupKernelNodeLegacy MintGroup = mintGroupKernelNodeLegacy
+)
+
 type MintData struct {
 	Group  string
 	Batch  uint64
@@ -29,6 +36,37 @@ func (m *MintData) Distribute(tx crypto.Hash) *MintDistribution {
 	}
 }
 
+func (tx *VersionedTransaction) MintKind() (MintGroup, error) {
+	if len(tx.Inputs) != 1 || tx.Inputs[0].Mint == nil {
+		return "", fmt.Errorf("not a mint transaction %s", tx.PayloadHash())
+	}
+	switch g := MintGroup(tx.Inputs[0].Mint.Group); g {
+	case MintGroupUniversal, MintGroupKernelNodeLegacy:
+		return g, nil
+	default:
+		return "", fmt.Errorf("invalid mint group %s", g)
+	}
+}
+
+// the kernel node and custodian outputs are threshold 1, and the light
+// pool output is Operator64, any other script is unexpected in a mint
+func (tx *VersionedTransaction) OutputsByScriptKind() map[string][]int {
+	kinds := make(map[string][]int)
+	for i, out := range tx.Outputs {
+		kind := "other"
+		if out.Script.VerifyFormat() == nil {
+			switch out.Script[2] {
+			case 1:
+				kind = "threshold1"
+			case Operator64:
+				kind = "operator64"
+			}
+		}
+		kinds[kind] = append(kinds[kind], i)
+	}
+	return kinds
+}
+
 func (tx *VersionedTransaction) validateMint(store DataStore) error {
 	if len(tx.Inputs) != 1 {
 		return fmt.Errorf("invalid inputs count %d for mint", len(tx.Inputs))
@@ -157,3 +195,49 @@ func UnmarshalMintDistribution(b []byte) (*MintDistribution, error) {
 	err = dec.Read(m.Transaction[:])
 	return &m, err
 }
+
+type MintAttempt struct {
+	Timestamp uint64
+	Batch     uint64
+	Outcome   string
+	Error     string
+}
+
+func (m *MintAttempt) Marshal() []byte {
+	enc := NewMinimumEncoder()
+	enc.WriteUint64(m.Timestamp)
+	enc.WriteUint64(m.Batch)
+	enc.WriteInt(len(m.Outcome))
+	enc.Write([]byte(m.Outcome))
+	enc.WriteInt(len(m.Error))
+	enc.Write([]byte(m.Error))
+	return enc.Bytes()
+}
+
+func UnmarshalMintAttempt(b []byte) (*MintAttempt, error) {
+	dec, err := NewMinimumDecoder(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var m MintAttempt
+	m.Timestamp, err = dec.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	m.Batch, err = dec.ReadUint64()
+	if err != nil {
+		return nil, err
+	}
+	outcome, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	m.Outcome = string(outcome)
+	reason, err := dec.ReadBytes()
+	if err != nil {
+		return nil, err
+	}
+	m.Error = string(reason)
+	return &m, nil
+}
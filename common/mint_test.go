@@ -0,0 +1,60 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMintKind(t *testing.T) {
+	require := require.New(t)
+
+	tx := NewTransactionV4(XINAssetId)
+	tx.AddUniversalMintInput(1616, NewInteger(100))
+	kind, err := tx.AsVersioned().MintKind()
+	require.Nil(err)
+	require.Equal(MintGroupUniversal, kind)
+
+	tx = NewTransactionV4(XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(1000, NewInteger(100))
+	kind, err = tx.AsVersioned().MintKind()
+	require.Nil(err)
+	require.Equal(MintGroupKernelNodeLegacy, kind)
+
+	tx = NewTransactionV4(XINAssetId)
+	tx.Inputs = []*Input{{Mint: &MintData{Group: "LIGHTNODE", Batch: 1, Amount: NewInteger(1)}}}
+	_, err = tx.AsVersioned().MintKind()
+	require.NotNil(err)
+
+	tx = NewTransactionV4(XINAssetId)
+	tx.AddInput(crypto.NewHash([]byte("MINTKIND")), 0)
+	kind, err = tx.AsVersioned().MintKind()
+	require.NotNil(err)
+	require.Equal(MintGroup(""), kind)
+}
+
+func TestOutputsByScriptKind(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	addr := NewAddressFromSeed(seed)
+	tx := NewTransactionV4(XINAssetId)
+	tx.AddUniversalMintInput(1616, NewInteger(100))
+	for i, script := range []Script{
+		NewThresholdScript(1),
+		NewThresholdScript(Operator64),
+		NewThresholdScript(1),
+		NewThresholdScript(2),
+		{OperatorSum, OperatorCmp, 1},
+	} {
+		seed[0] = byte(i)
+		tx.AddScriptOutput([]*Address{&addr}, script, NewInteger(20), append([]byte{}, seed...))
+	}
+
+	kinds := tx.AsVersioned().OutputsByScriptKind()
+	require.Len(kinds, 3)
+	require.Equal([]int{0, 2}, kinds["threshold1"])
+	require.Equal([]int{1}, kinds["operator64"])
+	require.Equal([]int{3, 4}, kinds["other"])
+}
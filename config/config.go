@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -31,6 +33,11 @@ const (
 	KernelMintTimeBegin = 7
 	KernelMintTimeEnd   = 9
 
+	// the common transaction versions, the common package imports this one,
+	// and mainnet ignores them to follow the mint fork schedule
+	MintTxVersionMinimum = 2
+	MintTxVersionLatest  = 4
+
 	KernelNodeAcceptTimeBegin     = 13
 	KernelNodeAcceptTimeEnd       = 19
 	KernelNodePledgePeriodMinimum = 12 * time.Hour
@@ -38,14 +45,23 @@ const (
 	KernelNodeAcceptPeriodMaximum = 7 * 24 * time.Hour
 )
 
+// the amount has at most 8 decimals, the same as common.Integer
+var mintAmountPattern = regexp.MustCompile(`^[0-9]+(\.[0-9]{1,8})?$`)
+
 type Custom struct {
 	Node struct {
-		Signer               crypto.Key `toml:"-"`
-		SignerStr            string     `toml:"signer-key"`
-		ConsensusOnly        bool       `toml:"consensus-only"`
-		KernelOprationPeriod int        `toml:"kernel-operation-period"`
-		MemoryCacheSize      int        `toml:"memory-cache-size"`
-		CacheTTL             int        `toml:"cache-ttl"`
+		Signer                 crypto.Key `toml:"-"`
+		SignerStr              string     `toml:"signer-key"`
+		ConsensusOnly          bool       `toml:"consensus-only"`
+		KernelOprationPeriod   int        `toml:"kernel-operation-period"`
+		MemoryCacheSize        int        `toml:"memory-cache-size"`
+		CacheTTL               int        `toml:"cache-ttl"`
+		ConflictBackoffBase    int        `toml:"conflict-backoff-base"`
+		ConflictBackoffLimit   int        `toml:"conflict-backoff-limit"`
+		WorkOffsetTimeout      int        `toml:"work-offset-timeout"`
+		MintTransactionVersion int        `toml:"mint-transaction-version"`
+		MintRotation           bool       `toml:"mint-rotation"`
+		MinMintAmount          string     `toml:"min-mint-amount"`
 	} `toml:"node"`
 	Storage struct {
 		ValueLogGC          bool `toml:"value-log-gc"`
@@ -80,6 +96,23 @@ func Initialize(file string) (*Custom, error) {
 		return nil, err
 	}
 	config.Node.Signer = key
+	config.setDefaults()
+	if v := config.Node.MintTransactionVersion; v != 0 && (v < MintTxVersionMinimum || v > MintTxVersionLatest) {
+		return nil, fmt.Errorf("invalid mint transaction version %d", v)
+	}
+	if a := config.Node.MinMintAmount; !mintAmountPattern.MatchString(a) {
+		return nil, fmt.Errorf("invalid min mint amount %s", a)
+	}
+	return &config, nil
+}
+
+func Default() *Custom {
+	var config Custom
+	config.setDefaults()
+	return &config
+}
+
+func (config *Custom) setDefaults() {
 	if config.Node.KernelOprationPeriod == 0 {
 		config.Node.KernelOprationPeriod = 700
 	}
@@ -89,5 +122,16 @@ func Initialize(file string) (*Custom, error) {
 	if config.Node.CacheTTL == 0 {
 		config.Node.CacheTTL = 3600 * 2
 	}
-	return &config, nil
+	if config.Node.ConflictBackoffBase == 0 {
+		config.Node.ConflictBackoffBase = 100
+	}
+	if config.Node.ConflictBackoffLimit == 0 {
+		config.Node.ConflictBackoffLimit = 3000
+	}
+	if config.Node.WorkOffsetTimeout == 0 {
+		config.Node.WorkOffsetTimeout = 30
+	}
+	if config.Node.MinMintAmount == "" {
+		config.Node.MinMintAmount = "0"
+	}
 }
@@ -1,6 +1,9 @@
 package config
 
 import (
+	"fmt"
+	"os"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -17,6 +20,12 @@ func TestConfig(t *testing.T) {
 	require.Equal(700, custom.Node.KernelOprationPeriod)
 	require.Equal(4096, custom.Node.MemoryCacheSize)
 	require.Equal(7200, custom.Node.CacheTTL)
+	require.Equal(100, custom.Node.ConflictBackoffBase)
+	require.Equal(3000, custom.Node.ConflictBackoffLimit)
+	require.Equal(30, custom.Node.WorkOffsetTimeout)
+	require.Equal(0, custom.Node.MintTransactionVersion)
+	require.Equal(false, custom.Node.MintRotation)
+	require.Equal("0", custom.Node.MinMintAmount)
 
 	require.Equal(true, custom.Storage.ValueLogGC)
 	require.Equal(7, custom.Storage.MaxCompactionLevels)
@@ -25,4 +34,35 @@ func TestConfig(t *testing.T) {
 	require.Len(custom.Network.Peers, 27)
 	require.Equal("lehigh-2.hotot.org:7239", custom.Network.Peers[26])
 	require.Equal(false, custom.RPC.Runtime)
+
+	custom = Default()
+	require.False(custom.Node.Signer.HasValue())
+	require.Equal(700, custom.Node.KernelOprationPeriod)
+	require.Equal(4096, custom.Node.MemoryCacheSize)
+	require.Equal(7200, custom.Node.CacheTTL)
+	require.Equal(30, custom.Node.WorkOffsetTimeout)
+	require.Equal(0, custom.Node.MintTransactionVersion)
+	require.Equal("0", custom.Node.MinMintAmount)
+
+	data, err := os.ReadFile("./config.example.toml")
+	require.Nil(err)
+	file := t.TempDir() + "/config.toml"
+	initialize := func(old, new string) (*Custom, error) {
+		data := []byte(strings.Replace(string(data), old, new, 1))
+		err := os.WriteFile(file, data, 0644)
+		require.Nil(err)
+		return Initialize(file)
+	}
+	_, err = initialize("mint-transaction-version = 0", "mint-transaction-version = 5")
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid mint transaction version 5")
+
+	custom, err = initialize(`min-mint-amount = "0"`, `min-mint-amount = "0.01"`)
+	require.Nil(err)
+	require.Equal("0.01", custom.Node.MinMintAmount)
+	for _, a := range []string{"-1", "0.000000001", "1e3", "abc"} {
+		_, err = initialize(`min-mint-amount = "0"`, fmt.Sprintf(`min-mint-amount = "%s"`, a))
+		require.NotNil(err)
+		require.Contains(err.Error(), "invalid min mint amount "+a)
+	}
 }
@@ -67,6 +67,10 @@ type Chain struct {
 
 	persistStore     storage.Store
 	finalActionsRing ActionBuffer
+	onWorkCaughtUp   func(round uint64)
+	workRounds       uint64
+	workSnapshots    uint64
+	workLimit        *workRoundLimit
 	plc              chan struct{}
 	clc              chan struct{}
 	wlc              chan struct{}
@@ -363,7 +363,8 @@ func (node *Node) validateNodeAcceptSnapshot(s *common.Snapshot, tx *common.Vers
 
 func (node *Node) reloadConsensusState(s *common.Snapshot, tx *common.VersionedTransaction) error {
 	if tx.TransactionType() == common.TransactionTypeMint {
-		mint, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+		node.resetLastMintDistribution()
+		mint, err := node.LastMintDistribution()
 		if err != nil {
 			return err
 		}
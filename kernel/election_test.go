@@ -8,6 +8,7 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel/internal"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/dgraph-io/ristretto"
 	"github.com/stretchr/testify/require"
@@ -73,6 +74,45 @@ ring-final-size = 16384
 [network]
 listener = "mixin-node.example.com:7239"`)
 
+func TestAcceptedNodesCache(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-election-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	_, err = node.AcceptedNodes(node.Epoch - uint64(time.Hour*24))
+	require.NotNil(err)
+	nodes, err := node.AcceptedNodes(node.Epoch + 1)
+	require.Nil(err)
+	require.Len(nodes, 15)
+	require.Len(node.nodesListCache.lists, 1)
+
+	ts := node.Epoch + 100*uint64(time.Hour*24)
+	nodes, err = node.AcceptedNodes(ts)
+	require.Nil(err)
+	require.Equal(node.NodesListWithoutState(ts, true), nodes)
+	require.Len(node.nodesListCache.lists, 2)
+	all := node.nodesListByDay(ts+uint64(time.Hour), false)
+	require.Equal(node.NodesListWithoutState(ts, false), all)
+	require.Len(node.nodesListCache.lists, 2)
+	states := node.nodesListByDay(ts, true)
+	require.Equal(node.allNodesSortedWithState[:len(states)], states)
+	require.Len(states, len(node.allNodesSortedWithState))
+	require.Len(node.nodesListCache.lists, 3)
+
+	err = node.LoadConsensusNodes()
+	require.Nil(err)
+	require.Len(node.nodesListCache.lists, 0)
+	nodes, err = node.AcceptedNodes(ts)
+	require.Nil(err)
+	require.Len(nodes, 15)
+}
+
 func setupTestNode(require *require.Assertions, dir string) *Node {
 	err := os.WriteFile(dir+"/config.toml", configData, 0644)
 	require.Nil(err)
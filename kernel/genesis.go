@@ -1,14 +1,21 @@
 package kernel
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/ristretto"
 )
 
 const (
@@ -28,25 +35,105 @@ type Genesis struct {
 	} `json:"domains"`
 }
 
+// the genesis epoch is in unix seconds, while the node epoch is in nanoseconds
+func (gns *Genesis) EpochTime() time.Time {
+	return time.Unix(gns.Epoch, 0).UTC()
+}
+
 func (node *Node) LoadGenesis(configDir string) error {
-	gns, err := readGenesis(configDir + "/genesis.json")
+	gns, err := readGenesis(filepath.Join(configDir, "genesis.json"))
 	if err != nil {
 		return err
 	}
+	return node.loadGenesis(gns)
+}
 
-	data, err := json.Marshal(gns)
+// the fallback is only used when the genesis file is missing, e.g. a genesis
+// embedded in the binary, and a malformed genesis file is still an error
+func (node *Node) LoadGenesisOrDefault(configDir string, fallback []byte) error {
+	err := node.LoadGenesis(configDir)
+	if !errors.Is(err, ErrGenesisNotFound) {
+		return err
+	}
+	gns, err := readGenesisFrom(fallback)
 	if err != nil {
 		return err
 	}
-	node.Epoch = uint64(time.Unix(gns.Epoch, 0).UnixNano())
-	node.networkId = crypto.NewHash(data)
-	node.IdForNetwork = node.Signer.Hash().ForNetwork(node.networkId)
+	return node.loadGenesis(gns)
+}
+
+// the node is backed by an in memory store and only has the account address,
+// so it can't sign anything, and is mostly useful for tests, the chain loops
+// are never booted so nothing needs to be stopped after use
+func LoadGenesisInMemory(g *Genesis, account common.Address) (*Node, error) {
+	err := validateGenesis(g)
+	if err != nil {
+		return nil, err
+	}
+
+	custom := config.Default()
+	store, err := storage.NewMemoryStore(custom)
+	if err != nil {
+		return nil, err
+	}
+	cost := int64(custom.Node.MemoryCacheSize * 1024 * 1024)
+	cache, err := ristretto.NewCache(&ristretto.Config{
+		NumCounters: cost / 1024 * 10,
+		MaxCost:     cost,
+		BufferItems: 64,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	node := newNode(custom, store, cache, "", "")
+	node.Signer = account
+	err = node.loadState(g)
+	if err != nil {
+		return nil, err
+	}
+	node.chain = node.getOrCreateChain(node.IdForNetwork)
+	return node, nil
+}
+
+// the network id doesn't depend on any node account, so a genesis file
+// can be verified offline without setting up a node
+func VerifyGenesisFile(path string) (crypto.Hash, error) {
+	gns, err := readGenesis(path)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	networkId, _, err := ComputeNetworkIdentity(gns, common.Address{})
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	epoch := uint64(gns.EpochTime().UnixNano())
+	_, _, _, err = buildGenesisSnapshots(networkId, epoch, gns)
+	if err != nil {
+		return crypto.Hash{}, err
+	}
+	return networkId, nil
+}
+
+func (node *Node) loadGenesis(gns *Genesis) error {
+	networkId, idForNetwork, err := ComputeNetworkIdentity(gns, node.Signer)
+	if err != nil {
+		return err
+	}
+	node.Epoch = uint64(gns.EpochTime().UnixNano())
+	node.networkId = networkId
+	node.IdForNetwork = idForNetwork
 	for _, in := range gns.Nodes {
 		id := in.Signer.Hash().ForNetwork(node.networkId)
 		node.genesisNodesMap[id] = true
 		node.genesisNodes = append(node.genesisNodes, id)
 	}
 
+	err = node.VerifyGenesisMatches(gns)
+	if err != nil {
+		return err
+	}
+
 	rounds, snapshots, transactions, err := buildGenesisSnapshots(node.networkId, node.Epoch, gns)
 	if err != nil {
 		return err
@@ -60,23 +147,41 @@ func (node *Node) LoadGenesis(configDir string) error {
 	return node.persistStore.LoadGenesis(rounds, snapshots, transactions)
 }
 
+func ComputeNetworkIdentity(g *Genesis, account common.Address) (crypto.Hash, crypto.Hash, error) {
+	data, err := json.Marshal(g)
+	if err != nil {
+		return crypto.Hash{}, crypto.Hash{}, err
+	}
+	networkId := crypto.NewHash(data)
+	return networkId, account.Hash().ForNetwork(networkId), nil
+}
+
+func (node *Node) VerifyGenesisMatches(g *Genesis) error {
+	networkId, _, err := ComputeNetworkIdentity(g, node.Signer)
+	if err != nil {
+		return err
+	}
+	if node.networkId.HasValue() && networkId != node.networkId {
+		return fmt.Errorf("genesis network id mismatch %s %s", networkId, node.networkId)
+	}
+
+	_, txs, err := node.persistStore.ReadSnapshotWithTransactionsSinceTopology(0, 1)
+	if err != nil || len(txs) == 0 {
+		return err
+	}
+	stored := txs[0].Inputs[0].Genesis
+	if !bytes.Equal(stored, networkId[:]) {
+		return fmt.Errorf("genesis network id mismatch %s %x", networkId, stored)
+	}
+	return nil
+}
+
 func buildGenesisSnapshots(networkId crypto.Hash, epoch uint64, gns *Genesis) ([]*common.Round, []*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error) {
 	var snapshots []*common.SnapshotWithTopologicalOrder
 	var transactions []*common.VersionedTransaction
 	cacheRounds := make(map[crypto.Hash]*CacheRound)
 	for i, in := range gns.Nodes {
-		si := crypto.NewHash([]byte(in.Signer.String() + "NODEACCEPT"))
-		seed := append(si[:], si[:]...)
-		script := common.NewThresholdScript(uint8(len(gns.Nodes)*2/3 + 1))
-		accounts := []*common.Address{}
-		for _, d := range gns.Nodes {
-			accounts = append(accounts, &d.Signer)
-		}
-
-		tx := common.NewTransactionV3(common.XINAssetId)
-		tx.Inputs = []*common.Input{{Genesis: networkId[:]}}
-		tx.AddOutputWithType(common.OutputTypeNodeAccept, accounts, script, pledgeAmount(0), seed)
-		tx.Extra = append(in.Signer.PublicSpendKey[:], in.Payee.PublicSpendKey[:]...)
+		tx := buildGenesisPledgeTransaction(networkId, in.Signer, in.Payee, gns)
 
 		nodeId := in.Signer.Hash().ForNetwork(networkId)
 		snapshot := &common.Snapshot{
@@ -91,6 +196,10 @@ func buildGenesisSnapshots(networkId crypto.Hash, epoch uint64, gns *Genesis) ([
 			signed.Version = 1
 			signed, _ = common.UnmarshalVersionedTransaction(signed.Marshal())
 		}
+		err := validateGenesisTransaction(networkId, signed, 64)
+		if err != nil {
+			return nil, nil, nil, err
+		}
 		snapshot.AddSoleTransaction(signed.PayloadHash())
 		snapshot.Hash = snapshot.PayloadHash()
 		topo := &common.SnapshotWithTopologicalOrder{
@@ -112,6 +221,10 @@ func buildGenesisSnapshots(networkId crypto.Hash, epoch uint64, gns *Genesis) ([
 		return nil, nil, nil, err
 	}
 	topo, signed := buildDomainSnapshot(networkId, epoch, domain.Signer, gns)
+	err := validateGenesisTransaction(networkId, signed, 32)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	snapshots = append(snapshots, topo)
 	transactions = append(transactions, signed)
 	snap := topo.Snapshot
@@ -147,10 +260,87 @@ func buildGenesisSnapshots(networkId crypto.Hash, epoch uint64, gns *Genesis) ([
 	return rounds, snapshots, transactions, nil
 }
 
+// the genesis input has no UTXO and the accept outputs have keys and script,
+// so the transaction can not pass the common validation, and only the format
+// is validated here, the extra is the signer and payee, or the domain key
+func validateGenesisTransaction(networkId crypto.Hash, ver *common.VersionedTransaction, extra int) error {
+	hash := ver.PayloadHash()
+	if ver.Asset != common.XINAssetId {
+		return fmt.Errorf("invalid genesis transaction asset %s %s", hash, ver.Asset)
+	}
+	if len(ver.Inputs) != 1 || !bytes.Equal(ver.Inputs[0].Genesis, networkId[:]) {
+		return fmt.Errorf("invalid genesis transaction input %s", hash)
+	}
+	if len(ver.Outputs) != 1 {
+		return fmt.Errorf("invalid genesis transaction outputs count %s %d", hash, len(ver.Outputs))
+	}
+	if len(ver.Extra) != extra {
+		return fmt.Errorf("invalid genesis transaction extra %s %d", hash, len(ver.Extra))
+	}
+
+	out := ver.Outputs[0]
+	if out.Amount.Sign() <= 0 {
+		return fmt.Errorf("invalid genesis output amount %s %s", hash, out.Amount)
+	}
+	if len(out.Keys) > common.SliceCountLimit {
+		return fmt.Errorf("invalid genesis output keys count %s %d", hash, len(out.Keys))
+	}
+	err := out.Script.Validate(len(out.Keys))
+	if err != nil {
+		return fmt.Errorf("invalid genesis output script %s %v", hash, err)
+	}
+	if !out.Mask.HasValue() {
+		return fmt.Errorf("invalid genesis output empty mask %s", hash)
+	}
+	filter := make(map[crypto.Key]bool)
+	for _, k := range out.Keys {
+		if filter[*k] || !k.CheckKey() {
+			return fmt.Errorf("invalid genesis output key %s %s", hash, k)
+		}
+		filter[*k] = true
+	}
+	return nil
+}
+
+func (node *Node) GenesisPledge(addr common.Address) (*common.Output, error) {
+	gns, err := readGenesis(filepath.Join(node.configDir, "genesis.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, in := range gns.Nodes {
+		if in.Signer.String() != addr.String() {
+			continue
+		}
+		tx := buildGenesisPledgeTransaction(node.networkId, in.Signer, in.Payee, gns)
+		return tx.Outputs[0], nil
+	}
+	return nil, fmt.Errorf("genesis node not found %s", addr.String())
+}
+
+func buildGenesisPledgeTransaction(networkId crypto.Hash, signer, payee common.Address, gns *Genesis) *common.Transaction {
+	si := crypto.NewHash([]byte(signer.String() + "NODEACCEPT"))
+	seed := append(si[:], si[:]...)
+	script := PledgeScript(len(gns.Nodes))
+	accounts := []*common.Address{}
+	for _, d := range gns.Nodes {
+		accounts = append(accounts, &d.Signer)
+	}
+
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.Inputs = []*common.Input{{Genesis: networkId[:]}}
+	tx.AddOutputWithType(common.OutputTypeNodeAccept, accounts, script, pledgeAmount(0), seed)
+	tx.Extra = append(signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
+	return tx
+}
+
+func PledgeScript(nodeCount int) common.Script {
+	return common.NewThresholdScript(uint8(nodeCount*2/3 + 1))
+}
+
 func buildDomainSnapshot(networkId crypto.Hash, epoch uint64, domain common.Address, gns *Genesis) (*common.SnapshotWithTopologicalOrder, *common.VersionedTransaction) {
 	si := crypto.NewHash([]byte(domain.String() + "DOMAINACCEPT"))
 	seed := append(si[:], si[:]...)
-	script := common.NewThresholdScript(uint8(len(gns.Nodes)*2/3 + 1))
+	script := PledgeScript(len(gns.Nodes))
 	accounts := []*common.Address{}
 	for _, d := range gns.Nodes {
 		accounts = append(accounts, &d.Signer)
@@ -183,55 +373,106 @@ func buildDomainSnapshot(networkId crypto.Hash, epoch uint64, domain common.Addr
 
 func readGenesis(path string) (*Genesis, error) {
 	f, err := os.ReadFile(path)
-	if err != nil {
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w %s", ErrGenesisNotFound, path)
+	} else if err != nil {
 		return nil, err
 	}
+	return readGenesisFrom(f)
+}
 
+func readGenesisFrom(data []byte) (*Genesis, error) {
 	var gns Genesis
-	err = json.Unmarshal(f, &gns)
+	err := json.Unmarshal(data, &gns)
 	if err != nil {
 		return nil, err
 	}
+	err = validateGenesis(&gns)
+	if err != nil {
+		return nil, err
+	}
+	return &gns, nil
+}
+
+var (
+	ErrGenesisNotFound              = errors.New("genesis file not found")
+	ErrGenesisInsufficientRemaining = errors.New("genesis node balance insufficient for remaining")
+)
+
+// a genesis node balance must equal the pledge, so any positive minimum
+// remaining balance is never satisfied until the genesis allows more
+func (gns *Genesis) Validate(minRemaining common.Integer) error {
+	err := validateGenesis(gns)
+	if err != nil || minRemaining.Sign() <= 0 {
+		return err
+	}
+	required := pledgeAmount(0).Add(minRemaining)
+	for _, in := range gns.Nodes {
+		if in.Balance.Cmp(required) < 0 {
+			return fmt.Errorf("%w %s %s %s", ErrGenesisInsufficientRemaining,
+				in.Signer.String(), in.Balance.String(), required.String())
+		}
+	}
+	return nil
+}
+
+func validateGenesis(gns *Genesis) error {
 	if len(gns.Nodes) < MinimumNodeCount {
-		return nil, fmt.Errorf("invalid genesis inputs number %d/%d", len(gns.Nodes), MinimumNodeCount)
+		return fmt.Errorf("invalid genesis inputs number %d/%d", len(gns.Nodes), MinimumNodeCount)
 	}
 
 	inputsFilter := make(map[string]bool)
 	for _, in := range gns.Nodes {
 		_, err := common.NewAddressFromString(in.Signer.String())
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if in.Balance.Cmp(pledgeAmount(0)) != 0 {
-			return nil, fmt.Errorf("invalid genesis node input amount %s", in.Balance.String())
+			return fmt.Errorf("invalid genesis node input amount %s", in.Balance.String())
 		}
 		if inputsFilter[in.Signer.String()] {
-			return nil, fmt.Errorf("duplicated genesis node input %s", in.Signer.String())
+			return fmt.Errorf("duplicated genesis node input %s", in.Signer.String())
 		}
+		inputsFilter[in.Signer.String()] = true
 		privateView := in.Signer.PublicSpendKey.DeterministicHashDerive()
 		if privateView.Public() != in.Signer.PublicViewKey {
-			return nil, fmt.Errorf("invalid node key format %s %s",
+			return fmt.Errorf("invalid node key format %s %s",
 				privateView.Public().String(), in.Signer.PublicViewKey.String())
 		}
 		privateView = in.Payee.PublicSpendKey.DeterministicHashDerive()
 		if privateView.Public() != in.Payee.PublicViewKey {
-			return nil, fmt.Errorf("invalid node key format %s %s",
+			return fmt.Errorf("invalid node key format %s %s",
 				privateView.Public().String(), in.Payee.PublicViewKey.String())
 		}
 	}
 
 	if len(gns.Domains) != 1 {
-		return nil, fmt.Errorf("invalid genesis domain inputs count %d",
+		return fmt.Errorf("invalid genesis domain inputs count %d",
 			len(gns.Domains))
 	}
 	domain := gns.Domains[0]
 	if domain.Signer.String() != gns.Nodes[0].Signer.String() {
-		return nil, fmt.Errorf("invalid genesis domain input account %s %s",
+		return fmt.Errorf("invalid genesis domain input account %s %s",
 			domain.Signer.String(), gns.Nodes[0].Signer.String())
 	}
 	if domain.Balance.Cmp(common.NewInteger(50000)) != 0 {
-		return nil, fmt.Errorf("invalid genesis domain input amount %s",
+		return fmt.Errorf("invalid genesis domain input amount %s",
 			domain.Balance.String())
 	}
-	return &gns, nil
+
+	return nil
+}
+
+// the network id is derived from the nodes order, so only a new network
+// could sort its genesis, and an existing genesis is never sorted on load,
+// the first node is also the domain account, so it stays at the head, and
+// the nodes are sorted in a copy, so the input genesis is never changed
+func SortGenesisNodes(gns *Genesis) *Genesis {
+	sorted := *gns
+	sorted.Nodes = append(gns.Nodes[:0:0], gns.Nodes...)
+	nodes := sorted.Nodes[1:]
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Signer.String() < nodes[j].Signer.String()
+	})
+	return &sorted
 }
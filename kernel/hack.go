@@ -1,6 +1,8 @@
 package kernel
 
 import (
+	"encoding/hex"
+	"sort"
 	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
@@ -129,3 +131,24 @@ func transactionForkHackCheck(hash crypto.Hash) bool {
 	}
 	return TransactionDepositOutputsForkHacks[hs]
 }
+
+func MintWorkHackBatches() []uint64 {
+	batches := make([]uint64, 0, len(TransactionMintWorkHacks))
+	for b := range TransactionMintWorkHacks {
+		batches = append(batches, uint64(b))
+	}
+	sort.Slice(batches, func(i, j int) bool { return batches[i] < batches[j] })
+	return batches
+}
+
+func MintWorkHackTransaction(batch uint64) ([]byte, bool) {
+	raw, found := TransactionMintWorkHacks[int(batch)]
+	if !found {
+		return nil, false
+	}
+	rt, err := hex.DecodeString(raw)
+	if err != nil {
+		panic(raw)
+	}
+	return rt, true
+}
@@ -1,9 +1,19 @@
 package kernel
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
@@ -21,36 +31,49 @@ const (
 	MainnetMintWorkDistributionForkBatch = 729
 	MainnetMintTransactionV2ForkBatch    = 739
 	MainnetMintTransactionV3ForkBatch    = 1313
+	MainnetMintProducerForkBatch         = 2864 // 2027-01-01
+	MainnetMintZeroOutputForkBatch       = 2864 // 2027-01-01
+	MainnetMintThresholdForkBatch        = 2864 // 2027-01-01
+
+	MintAttemptsLimit       = 100
+	MintAttemptErrorMaximum = 1024
+	MintRebuiltCacheLimit   = 64
+)
+
+const (
+	MintYearShares  = 10
+	MintYearBatches = 365
+	MintNodeMaximum = 50
 )
 
+// the pools are never written after init, so they are safe to read
+// concurrently, and all the mint parameters are constants
 var (
-	MintPool        common.Integer
-	MintLiquidity   common.Integer
-	MintYearShares  int
-	MintYearBatches int
-	MintNodeMaximum int
+	MintPool      common.Integer
+	MintLiquidity common.Integer
 )
 
 func init() {
 	MintPool = common.NewInteger(500000)
 	MintLiquidity = common.NewInteger(500000)
-	MintYearShares = 10
-	MintYearBatches = 365
-	MintNodeMaximum = 50
 }
 
 func (chain *Chain) AggregateMintWork() {
 	logger.Printf("AggregateMintWork(%s)\n", chain.ChainId)
 	defer close(chain.wlc)
 
-	round, err := chain.persistStore.ReadWorkOffset(chain.ChainId)
+	// WriteRoundWork commits the works and the offset with its snapshots
+	// in one transaction, so the offset round is always resumed after a
+	// crash, and the snapshots already counted in it are filtered out
+	round, err := chain.readWorkOffset()
 	if err != nil {
-		panic(err)
+		logger.Printf("AggregateMintWork(%s) ERROR ReadWorkOffset %s\n", chain.ChainId, err.Error())
+		return
 	}
 	logger.Printf("AggregateMintWork(%s) begin with %d\n", chain.ChainId, round)
 
-	fork := uint64(SnapshotRoundDayLeapForkHack.UnixNano())
 	wait := time.Duration(chain.node.custom.Node.KernelOprationPeriod/2) * time.Second
+	caughtUp := false
 
 	for chain.running {
 		if cs := chain.State; cs == nil {
@@ -70,6 +93,7 @@ func (chain *Chain) AggregateMintWork() {
 		if crn < round {
 			panic(fmt.Errorf("AggregateMintWork(%s) waiting %d %d", chain.ChainId, crn, round))
 		}
+		last := chain.workRoundLimit(crn)
 		snapshots, err := chain.persistStore.ReadSnapshotWorksForNodeRound(chain.ChainId, round)
 		if err != nil {
 			logger.Verbosef("AggregateMintWork(%s) ERROR ReadSnapshotsForNodeRound %s\n", chain.ChainId, err.Error())
@@ -79,24 +103,23 @@ func (chain *Chain) AggregateMintWork() {
 			chain.waitOrDone(wait)
 			continue
 		}
-		for chain.running {
-			if chain.node.isMainnet() && snapshots[0].Timestamp < fork {
-				snapshots = nil
-			}
-			err = chain.persistStore.WriteRoundWork(chain.ChainId, round, snapshots)
-			if err == nil {
-				break
-			}
-			if errors.Is(err, badger.ErrConflict) {
-				logger.Verbosef("AggregateMintWork(%s) ERROR WriteRoundWork %s\n", chain.ChainId, err.Error())
-				time.Sleep(100 * time.Millisecond)
-				continue
-			}
+		// the fork filtered round is still written with nil snapshots, to
+		// advance the offset without any works counted for the round
+		snapshots = chain.filterRoundWork(snapshots)
+		err = chain.writeRoundWork(round, snapshots)
+		if err != nil {
 			panic(err)
 		}
-		if round < crn {
+		if round < last {
+			atomic.AddUint64(&chain.workRounds, 1)
+			atomic.AddUint64(&chain.workSnapshots, uint64(len(snapshots)))
 			round = round + 1
+			caughtUp = false
 		} else {
+			if !caughtUp {
+				chain.notifyWorkCaughtUp(round)
+			}
+			caughtUp = true
 			chain.waitOrDone(wait)
 		}
 	}
@@ -104,98 +127,483 @@ func (chain *Chain) AggregateMintWork() {
 	logger.Printf("AggregateMintWork(%s) end with %d\n", chain.ChainId, round)
 }
 
+// the hook is called in the aggregator loop once the work round reaches the
+// cache round, and again only after it falls behind and catches up
+func (chain *Chain) SetWorkCaughtUpHook(hook func(round uint64)) {
+	chain.Lock()
+	defer chain.Unlock()
+	chain.onWorkCaughtUp = hook
+}
+
+func (chain *Chain) notifyWorkCaughtUp(round uint64) {
+	chain.RLock()
+	hook := chain.onWorkCaughtUp
+	chain.RUnlock()
+	if hook != nil {
+		hook(round)
+	}
+}
+
+func (chain *Chain) filterRoundWork(snapshots []*common.SnapshotWork) []*common.SnapshotWork {
+	fork := uint64(SnapshotRoundDayLeapForkHack.UnixNano())
+	if chain.node.isMainnet() && snapshots[0].Timestamp < fork {
+		return nil
+	}
+	return snapshots
+}
+
+// the snapshot works are removed once the round is aggregated, so the works
+// are recomputed from the snapshots of the round with their cosi signers,
+// then filtered exactly as AggregateMintWork does, but nothing is written
+func (chain *Chain) RecomputeRoundWork(round uint64) ([]*common.SnapshotWork, error) {
+	snapshots, err := chain.persistStore.ReadSnapshotsForNodeRound(chain.ChainId, round)
+	if err != nil || len(snapshots) == 0 {
+		return nil, err
+	}
+	return chain.filterRoundWork(chain.snapshotWorks(snapshots)), nil
+}
+
+func (chain *Chain) snapshotWorks(snapshots []*common.SnapshotWithTopologicalOrder) []*common.SnapshotWork {
+	works := make([]*common.SnapshotWork, len(snapshots))
+	for i, s := range snapshots {
+		signers, _ := chain.verifyFinalization(s.Snapshot)
+		works[i] = &common.SnapshotWork{
+			Hash:      s.Hash,
+			Timestamp: s.Timestamp,
+			Signers:   signers,
+		}
+	}
+	return works
+}
+
+func (chain *Chain) writeRoundWork(round uint64, snapshots []*common.SnapshotWork) error {
+	custom := chain.node.custom.Node
+	base := time.Duration(custom.ConflictBackoffBase) * time.Millisecond
+	limit := time.Duration(custom.ConflictBackoffLimit) * time.Millisecond
+	for i := 0; chain.running; i++ {
+		err := chain.persistStore.WriteRoundWork(chain.ChainId, round, snapshots)
+		if !errors.Is(err, badger.ErrConflict) {
+			return err
+		}
+		logger.Verbosef("AggregateMintWork(%s) ERROR WriteRoundWork %s\n", chain.ChainId, err.Error())
+		time.Sleep(conflictBackoff(base, limit, i))
+	}
+	return nil
+}
+
+// a transient store error at startup is retried with the conflict backoff,
+// until the work offset timeout, then the aggregator stops on the error
+func (chain *Chain) readWorkOffset() (uint64, error) {
+	custom := chain.node.custom.Node
+	base := time.Duration(custom.ConflictBackoffBase) * time.Millisecond
+	limit := time.Duration(custom.ConflictBackoffLimit) * time.Millisecond
+	timeout := time.Duration(custom.WorkOffsetTimeout) * time.Second
+	start := time.Now()
+	for i := 0; ; i++ {
+		round, err := chain.persistStore.ReadWorkOffset(chain.ChainId)
+		if err == nil {
+			return round, nil
+		}
+		if !chain.running || time.Since(start) >= timeout {
+			return 0, err
+		}
+		logger.Verbosef("AggregateMintWork(%s) ERROR ReadWorkOffset %s\n", chain.ChainId, err.Error())
+		time.Sleep(conflictBackoff(base, limit, i))
+	}
+}
+
+func conflictBackoff(base, limit time.Duration, attempt int) time.Duration {
+	wait := base
+	for i := 0; i < attempt && wait < limit; i++ {
+		wait = wait * 2
+	}
+	if wait > limit {
+		return limit
+	}
+	return wait
+}
+
+func (chain *Chain) AggregationStats() (uint64, uint64) {
+	rounds := atomic.LoadUint64(&chain.workRounds)
+	snapshots := atomic.LoadUint64(&chain.workSnapshots)
+	return rounds, snapshots
+}
+
+// the aggregated rounds whose earliest snapshot is before the mainnet day
+// leap fork, and the works of these rounds were not counted
+func (chain *Chain) ForkFilteredRounds() ([]uint64, error) {
+	if !chain.node.isMainnet() {
+		return nil, nil
+	}
+	offset, err := chain.persistStore.ReadWorkOffset(chain.ChainId)
+	if err != nil {
+		return nil, err
+	}
+
+	fork := uint64(SnapshotRoundDayLeapForkHack.UnixNano())
+	var rounds []uint64
+	for round := uint64(0); round <= offset; round++ {
+		snapshots, err := chain.persistStore.ReadSnapshotsForNodeRound(chain.ChainId, round)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) == 0 {
+			continue
+		}
+		earliest := snapshots[0].Timestamp
+		for _, s := range snapshots {
+			if s.Timestamp < earliest {
+				earliest = s.Timestamp
+			}
+		}
+		if earliest >= fork {
+			break
+		}
+		rounds = append(rounds, round)
+	}
+	return rounds, nil
+}
+
+type workRoundLimit struct {
+	version uint64
+	removed bool
+	round   uint64
+}
+
+// the cache round of a removed node may still be updated, the aggregation
+// should stop at the last round with snapshots, so all nodes agree on it,
+// and the limit is only read again after the node list changes, it is only
+// used by the aggregator of the chain
+func (chain *Chain) workRoundLimit(crn uint64) uint64 {
+	version := chain.node.nodesListVersion()
+	if l := chain.workLimit; l == nil || (!l.removed && l.version != version) {
+		chain.workLimit = chain.readWorkRoundLimit(version)
+	}
+	if l := chain.workLimit; l != nil && l.removed && l.round < crn {
+		return l.round
+	}
+	return crn
+}
+
+func (chain *Chain) readWorkRoundLimit(version uint64) *workRoundLimit {
+	limit := &workRoundLimit{version: version}
+	rn := chain.node.GetRemovedOrCancelledNode(chain.ChainId, ^uint64(0))
+	if rn == nil || rn.State != common.NodeStateRemoved {
+		return limit
+	}
+	last, err := chain.persistStore.ReadLastSnapshotRound(chain.ChainId)
+	if err != nil {
+		logger.Verbosef("AggregateMintWork(%s) ERROR ReadLastSnapshotRound %s\n", chain.ChainId, err.Error())
+		return nil
+	}
+	limit.removed, limit.round = true, last
+	return limit
+}
+
 func (node *Node) MintLoop() {
 	defer close(node.mlc)
 
 	ticker := time.NewTicker(time.Duration(node.custom.Node.KernelOprationPeriod) * time.Second)
 	defer ticker.Stop()
 
+	var warned bool
 	for {
 		select {
 		case <-node.done:
 			return
 		case <-ticker.C:
-			cur, err := node.persistStore.ReadCustodian(node.GraphTimestamp)
-			if err != nil {
-				panic(err)
-			}
-			if cur == nil && node.isMainnet() {
-				err := node.tryToMintKernelNodeLegacy()
-				logger.Println(node.IdForNetwork, "tryToMintKernelNodeLegacy", err)
-			} else {
-				err = node.tryToMintUniversal(cur)
-				logger.Println(node.IdForNetwork, "tryToMintKernelUniversal", err)
+			if !node.hasSigner() {
+				if !warned {
+					logger.Printf("MintLoop skipped without signer %s\n", node.IdForNetwork)
+					warned = true
+				}
+				continue
 			}
+			node.mintTick()
+		}
+	}
+}
+
+func (node *Node) mintTick() {
+	if !node.inMintTimeWindow(node.GraphTimestamp) {
+		return
+	}
+	batch := node.mintBatch(node.GraphTimestamp)
+	if node.custom.Node.MintRotation && !node.IsDesignatedMinter(uint64(batch)) {
+		return
+	}
+	cur, err := node.persistStore.ReadCustodian(node.GraphTimestamp)
+	if err != nil {
+		logger.Printf("MintLoop ReadCustodian ERROR %s\n", err.Error())
+		node.recordMintAttempt(batch, err)
+		return
+	}
+	if pending, err := node.pendingMintProposal(); err != nil || pending > 0 {
+		logger.Verbosef("MintLoop pending mint proposal %d %v\n", pending, err)
+		return
+	}
+	var appended bool
+	if cur == nil && node.isMainnet() {
+		appended, err = node.tryToMintKernelNodeLegacy()
+		logger.Println(node.IdForNetwork, "tryToMintKernelNodeLegacy", appended, err)
+	} else {
+		appended, err = node.tryToMintUniversal(cur)
+		logger.Println(node.IdForNetwork, "tryToMintKernelUniversal", appended, err)
+	}
+	// most ticks have nothing to mint, and only the real attempts are recorded
+	if appended || err != nil {
+		node.recordMintAttempt(batch, err)
+	}
+}
+
+// the rotation is only a local filter to reduce the concurrent mint attempts,
+// any accepted node could still mint, and the validation doesn't check it
+func (node *Node) IsDesignatedMinter(batch uint64) bool {
+	accepted := node.acceptedNodesByDay(node.mintTimestamp(batch))
+	if len(accepted) == 0 {
+		return false
+	}
+	ids := make([]crypto.Hash, len(accepted))
+	for i, n := range accepted {
+		ids[i] = n.IdForNetwork
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i].String() < ids[j].String()
+	})
+	return ids[batch%uint64(len(ids))] == node.IdForNetwork
+}
+
+// the public keys are always derived from the configured signer key, even
+// when it is empty, so only the private key or an external signer counts
+func (node *Node) hasSigner() bool {
+	return node.MintSigner != nil || node.Signer.PrivateSpendKey.HasValue()
+}
+
+// the dust floor must be the same for all nodes of a network, and mainnet
+// never skips a mint by it to keep all the historical mints valid
+func (node *Node) minMintAmount() common.Integer {
+	if node.isMainnet() || node.custom == nil || node.custom.Node.MinMintAmount == "" {
+		return common.Zero
+	}
+	return common.NewIntegerFromString(node.custom.Node.MinMintAmount)
+}
+
+// this is only a cheap filter before building the mint transaction,
+// the validation always goes through the complete possibility check
+func (node *Node) inMintTimeWindow(timestamp uint64) bool {
+	if timestamp <= node.Epoch {
+		return false
+	}
+	hours := int((timestamp - node.Epoch) / 3600000000000)
+	kmb, kme := node.mintWindow(hours / 24)
+	return hours%24 >= kmb && hours%24 <= kme
+}
+
+func (node *Node) MintWindow() (int, int) {
+	return node.mintWindow(node.mintBatch(node.GraphTimestamp))
+}
+
+func (node *Node) mintWindow(batch int) (int, int) {
+	if node.isMainnet() && batch < MainnetMintPeriodForkBatch {
+		return MainnetMintPeriodForkTimeBegin, MainnetMintPeriodForkTimeEnd
+	}
+	return config.KernelMintTimeBegin, config.KernelMintTimeEnd
+}
+
+func (node *Node) recordMintAttempt(batch int, err error) {
+	attempt := &common.MintAttempt{
+		Timestamp: uint64(clock.Now().UnixNano()),
+		Batch:     uint64(batch),
+		Outcome:   "success",
+	}
+	if err != nil {
+		attempt.Outcome = "failed"
+		attempt.Error = err.Error()
+		if len(attempt.Error) > MintAttemptErrorMaximum {
+			attempt.Error = attempt.Error[:MintAttemptErrorMaximum]
+		}
+	}
+	err = node.persistStore.WriteMintAttempt(attempt, MintAttemptsLimit)
+	if err != nil {
+		logger.Printf("recordMintAttempt(%d) ERROR %s\n", batch, err.Error())
+	}
+}
+
+func (node *Node) RecentMintAttempts() ([]*common.MintAttempt, error) {
+	return node.persistStore.ListMintAttempts()
+}
+
+type MintHealthReport struct {
+	LastMintBatch uint64 `json:"last_mint_batch"`
+	CurrentBatch  uint64 `json:"current_batch"`
+	WorkOffsetLag uint64 `json:"work_offset_lag"`
+	AcceptedNodes int    `json:"accepted_nodes"`
+	Accepted      bool   `json:"accepted"`
+}
+
+// the work offset lag is the cache round of this node chain minus the last
+// aggregated work round, and all the fields are at the graph timestamp
+func (node *Node) MintSubsystemHealth() (*MintHealthReport, error) {
+	dist, err := node.LastMintDistribution()
+	if err != nil {
+		return nil, err
+	}
+	offset, err := node.persistStore.ReadWorkOffset(node.IdForNetwork)
+	if err != nil {
+		return nil, err
+	}
+
+	ts := node.GraphTimestamp
+	report := &MintHealthReport{
+		LastMintBatch: dist.Batch,
+		CurrentBatch:  uint64(node.mintBatch(ts)),
+	}
+	if chain := node.getChain(node.IdForNetwork); chain != nil && chain.State != nil {
+		if crn := chain.State.CacheRound.Number; crn > offset {
+			report.WorkOffsetLag = crn - offset
 		}
 	}
+	accepted := node.acceptedNodesByDay(ts)
+	report.AcceptedNodes = len(accepted)
+	for _, cn := range accepted {
+		if cn.IdForNetwork == node.IdForNetwork {
+			report.Accepted = true
+		}
+	}
+	return report, nil
+}
+
+func (node *Node) mintBatch(timestamp uint64) int {
+	if timestamp <= node.Epoch {
+		return 0
+	}
+	return int((timestamp - node.Epoch) / (uint64(time.Hour) * 24))
 }
 
-func (node *Node) tryToMintUniversal(custodianRequest *common.CustodianUpdateRequest) error {
-	signed := node.buildUniversalMintTransaction(custodianRequest, node.GraphTimestamp, false)
+func (node *Node) tryToMintUniversal(custodianRequest *common.CustodianUpdateRequest) (bool, error) {
+	if custodianRequest != nil && custodianRequest.Custodian != nil && !node.custodianAllowed(custodianRequest.Custodian) {
+		logger.Printf("tryToMintUniversal custodian not allowed %s\n", custodianRequest.Custodian)
+		return false, nil
+	}
+	err := node.checkWorksFinalizedBeforeProposal(node.GraphTimestamp)
+	if err != nil {
+		logger.Verbosef("tryToMintUniversal %v\n", err)
+		return false, nil
+	}
+	signed := node.buildUniversalMintTransaction(custodianRequest, node.GraphTimestamp, ^uint64(0), false)
 	if signed == nil {
-		return nil
+		return false, nil
 	}
 
-	err := signed.SignInput(node.persistStore, 0, []*common.Address{&node.Signer})
+	err = node.signMintTransaction(signed)
 	if err != nil {
-		return err
+		return false, err
 	}
 	err = signed.Validate(node.persistStore, false)
 	if err != nil {
-		return err
+		return false, err
+	}
+	err = node.preCommitMint(signed)
+	if err != nil {
+		return false, err
 	}
 	err = node.persistStore.CachePutTransaction(signed)
 	if err != nil {
-		return err
+		return false, err
+	}
+	return node.proposeMintSnapshot(signed)
+}
+
+// the proposed mint is pending until its distribution is finalized, or a
+// mint of the same or a later batch from any other node is finalized, and
+// it expires once the batch passed, then the next batch could be proposed
+func (node *Node) pendingMintProposal() (uint64, error) {
+	proposal, err := node.persistStore.ReadLastMintProposal()
+	if err != nil {
+		return 0, err
+	}
+	dist, err := node.LastMintDistribution()
+	if err != nil || proposal <= dist.Batch {
+		return 0, err
+	}
+	batch := uint64(node.mintBatch(node.GraphTimestamp))
+	if proposal >= batch {
+		return proposal, nil
+	}
+	logger.Printf("pendingMintProposal expired %d %d %d\n", proposal, dist.Batch, batch)
+	return 0, node.persistStore.WriteLastMintProposal(dist.Batch)
+}
+
+// a mint covers all the skipped batches since the last one, so there
+// should be only one mint snapshot for a batch, even after a restart,
+// and the proposal is only recorded once the snapshot is appended
+func (node *Node) proposeMintSnapshot(signed *common.VersionedTransaction) (bool, error) {
+	batch := signed.Inputs[0].Mint.Batch
+	last, err := node.persistStore.ReadLastMintProposal()
+	if err != nil || batch <= last {
+		return false, err
 	}
 	s := &common.Snapshot{
 		Version: common.SnapshotVersionCommonEncoding,
 		NodeId:  node.IdForNetwork,
 	}
 	s.AddSoleTransaction(signed.PayloadHash())
-	logger.Println("tryToMintUniversal", signed.PayloadHash(), hex.EncodeToString(signed.Marshal()))
-	return node.chain.AppendSelfEmpty(s)
+	logger.Println("proposeMintSnapshot", batch, signed.PayloadHash(), hex.EncodeToString(signed.Marshal()))
+	err = node.chain.AppendSelfEmpty(s)
+	if err != nil {
+		return false, err
+	}
+	return true, node.persistStore.WriteLastMintProposal(batch)
 }
 
-func (node *Node) buildUniversalMintTransaction(custodianRequest *common.CustodianUpdateRequest, timestamp uint64, validateOnly bool) *common.VersionedTransaction {
-	batch, amount := node.checkUniversalMintPossibility(timestamp, validateOnly)
+func (node *Node) buildUniversalMintTransaction(custodianRequest *common.CustodianUpdateRequest, timestamp, last uint64, validateOnly bool) *common.VersionedTransaction {
+	batch, amount := node.checkUniversalMintPossibility(timestamp, last, validateOnly)
 	if amount.Sign() <= 0 || batch <= 0 {
 		return nil
 	}
 
-	// TODO mint works should calculate according to finalized previous round, new fork required
+	if custodianRequest != nil && custodianRequest.Custodian == nil {
+		logger.Printf("buildUniversalMintTransaction malformed custodian request %d\n", batch)
+		custodianRequest = nil
+	}
+	domains := node.persistStore.ReadDomains()
+	if len(domains) == 0 && custodianRequest == nil {
+		logger.Printf("buildUniversalMintTransaction no domain or custodian %d\n", batch)
+		return nil
+	}
+
 	kernel := amount.Div(10).Mul(5)
-	accepted := node.NodesListWithoutState(timestamp, true)
+	accepted := node.acceptedNodesByDay(timestamp)
 	mints, err := node.distributeKernelMintByWorks(accepted, kernel, timestamp)
 	if err != nil {
 		logger.Printf("buildUniversalMintTransaction ERROR %s\n", err.Error())
 		return nil
 	}
 
-	tx := node.NewTransaction(common.XINAssetId)
+	tx := node.newMintTransaction()
 	tx.AddUniversalMintInput(uint64(batch), amount)
-	total := common.NewInteger(0)
-	for _, m := range mints {
-		in := fmt.Sprintf("MINTKERNELNODE%d", batch)
-		si := crypto.NewHash([]byte(m.Signer.String() + in))
-		seed := append(si[:], si[:]...)
-		script := common.NewThresholdScript(1)
-		tx.AddScriptOutput([]*common.Address{&m.Payee}, script, m.Work, seed)
-		total = total.Add(m.Work)
+	err = node.addKernelMintOutputs(tx, mints, batch)
+	if err != nil {
+		logger.Printf("buildUniversalMintTransaction ERROR %s\n", err.Error())
+		return nil
 	}
-	if total.Cmp(amount) > 0 {
-		panic(fmt.Errorf("buildUniversalMintTransaction %s %s", amount, total))
+	total, err := sumWorks(mints)
+	if err != nil {
+		logger.Printf("buildUniversalMintTransaction ERROR %s\n", err.Error())
+		return nil
 	}
+	if total.Cmp(kernel) > 0 {
+		panic(fmt.Errorf("buildUniversalMintTransaction %s %s", kernel, total))
+	}
+	remainder := universalKernelRemainder(kernel, total)
 
 	safe := amount.Div(10).Mul(4)
-	domains := node.persistStore.ReadDomains()
 	custodian := &domains[0].Account
 	if custodianRequest != nil {
 		custodian = custodianRequest.Custodian
 	}
 	in := fmt.Sprintf("MINTCUSTODIANACCOUNT%d", batch)
-	si := crypto.NewHash([]byte(custodian.String() + in))
-	seed := append(si[:], si[:]...)
+	seed := MintSeed(*custodian, in)
 	script := common.NewThresholdScript(1)
 	tx.AddScriptOutput([]*common.Address{custodian}, script, safe, seed)
 	total = total.Add(safe)
@@ -207,14 +615,137 @@ func (node *Node) buildUniversalMintTransaction(custodianRequest *common.Custodi
 	amount = tx.Inputs[0].Mint.Amount
 
 	// TODO use real light mint account when light node online
-	light := amount.Sub(total)
+	light, err := universalLightAmount(amount, kernel, safe, remainder, total)
+	if err != nil {
+		logger.Printf("buildUniversalMintTransaction ERROR %s\n", err.Error())
+		return nil
+	}
 	addr := common.NewAddressFromSeed(make([]byte, 64))
 	script = common.NewThresholdScript(common.Operator64)
 	in = fmt.Sprintf("MINTLIGHTACCOUNT%d", batch)
-	si = crypto.NewHash([]byte(addr.String() + in))
-	seed = append(si[:], si[:]...)
+	seed = MintSeed(addr, in)
 	tx.AddScriptOutput([]*common.Address{&addr}, script, light, seed)
-	return tx.AsVersioned()
+	ver := tx.AsVersioned()
+	err = checkMintOutputKeys(ver)
+	if err != nil {
+		logger.Printf("buildUniversalMintTransaction ERROR %s\n", err.Error())
+		return nil
+	}
+	return ver
+}
+
+// the Sub of common.Integer panics on a negative result, so the amounts are
+// compared before, and a light output not positive is malformed
+func universalLightAmount(amount, kernel, safe, remainder, total common.Integer) (common.Integer, error) {
+	if total.Cmp(amount) >= 0 || kernel.Add(safe).Cmp(amount) >= 0 {
+		return common.Zero, fmt.Errorf("invalid universal light amount %s %s %s %s", amount, kernel, safe, total)
+	}
+	light := amount.Sub(kernel).Sub(safe)
+	if remainder.Sign() > 0 {
+		light = light.Add(remainder)
+	}
+	if light.Cmp(amount.Sub(total)) != 0 {
+		panic(fmt.Errorf("buildUniversalMintTransaction %s %s %s", amount, total, light))
+	}
+	return light, nil
+}
+
+// at the emission tail a share may round down to zero, such an output is
+// skipped after the mainnet fork and the dust falls into the light pool or
+// the legacy diff output
+func (node *Node) addKernelMintOutputs(tx *common.Transaction, mints []*CNodeWork, batch int) error {
+	skip := !node.isMainnet() || batch >= MainnetMintZeroOutputForkBatch
+	script := common.NewThresholdScript(1)
+	in := fmt.Sprintf("MINTKERNELNODE%d", batch)
+	for _, m := range mints {
+		switch m.Work.Sign() {
+		case -1:
+			return fmt.Errorf("negative kernel mint %s %s", m.IdForNetwork, m.Work)
+		case 0:
+			if skip {
+				continue
+			}
+		}
+		seed := MintSeed(m.Signer, in)
+		tx.AddScriptOutput([]*common.Address{&m.Payee}, script, m.Work, seed)
+	}
+	return nil
+}
+
+// the zero works are skipped because they have no outputs
+func sumWorks(mints []*CNodeWork) (common.Integer, error) {
+	total := common.NewInteger(0)
+	for _, m := range mints {
+		switch m.Work.Sign() {
+		case -1:
+			return common.Zero, fmt.Errorf("negative kernel mint %s %s", m.IdForNetwork, m.Work)
+		case 1:
+			total = total.Add(m.Work)
+		}
+	}
+	return total, nil
+}
+
+// the works are rounded down, so the kernel part is not fully distributed,
+// and the remainder is folded into the light pool
+func universalKernelRemainder(kernel, works common.Integer) common.Integer {
+	if works.Cmp(kernel) >= 0 {
+		return common.Zero
+	}
+	return kernel.Sub(works)
+}
+
+// the seed of a mint output is derived from the account and the tag of the
+// output, MINTKERNELNODE%d for the kernel nodes with the signer account,
+// MINTCUSTODIANACCOUNT%d for the custodian, MINTLIGHTACCOUNT%d for the light
+// pool and MINTKERNELNODE%dDIFF for the legacy diff, %d is the mint batch
+func MintSeed(account common.Address, tag string) []byte {
+	si := crypto.NewHash([]byte(account.String() + tag))
+	return append(si[:], si[:]...)
+}
+
+// a ghost key collision makes one of the outputs unspendable
+func checkMintOutputKeys(tx *common.VersionedTransaction) error {
+	filter := make(map[crypto.Key]int)
+	for i, out := range tx.Outputs {
+		for _, k := range out.Keys {
+			if j, found := filter[*k]; found {
+				return fmt.Errorf("duplicated mint output key %s %d %d", k, j, i)
+			}
+			filter[*k] = i
+		}
+	}
+	return nil
+}
+
+// universal mint: one output per accepted node, custodian and light outputs
+// legacy mint: one output per accepted node, and the diff output if any,
+// both counts are the maximum because a zero node share has no output
+func (node *Node) ExpectedMintOutputCount(batch uint64) (int, error) {
+	if batch < 1 {
+		return 0, fmt.Errorf("invalid mint batch %d", batch)
+	}
+	timestamp := node.mintTimestamp(batch)
+	accepted := node.acceptedNodesByDay(timestamp)
+	if len(accepted) == 0 {
+		return 0, fmt.Errorf("no accepted nodes for mint batch %d", batch)
+	}
+
+	cur, err := node.persistStore.ReadCustodian(timestamp)
+	if err != nil {
+		return 0, err
+	}
+	if cur != nil || !node.isMainnet() {
+		return len(accepted) + 2, nil
+	}
+	if rt, found := MintWorkHackTransaction(batch); found {
+		ver, err := common.UnmarshalVersionedTransaction(rt)
+		if err != nil {
+			return 0, err
+		}
+		return len(ver.Outputs), nil
+	}
+	return len(accepted) + 1, nil
 }
 
 func (node *Node) tryToSlashLegacyLightPool(batch uint64, tx *common.Transaction) {
@@ -229,28 +760,434 @@ func (node *Node) tryToSlashLegacyLightPool(batch uint64, tx *common.Transaction
 	if mints[0].Group == mint.Group {
 		return
 	}
-	old := int(mints[0].Batch)
-	lightSlash := poolSizeLegacy(old).Sub(poolSizeUniversal(old))
+	lightSlash := PoolDivergence(int(mints[0].Batch))
 	mint.Amount = mint.Amount.Add(lightSlash)
 }
 
-func (node *Node) PoolSize() (common.Integer, error) {
-	dist, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+// the amount only depends on the previous distribution, and the custodian
+// at ts decides the legacy or universal curve, as in the mint validation,
+// it is zero when the universal mint is skipped below the minimum amount
+func (node *Node) ExpectedMintAmount(batch uint64, ts uint64) (common.Integer, error) {
+	if batch < 1 || uint64(node.mintBatch(ts)) != batch {
+		return common.Zero, fmt.Errorf("invalid mint batch %d at %d", batch, ts)
+	}
+	prev, err := node.persistStore.ReadLastMintDistribution(batch - 1)
 	if err != nil {
 		return common.Zero, err
 	}
-	if dist.Group == "KERNELNODE" {
-		return poolSizeLegacy(int(dist.Batch)), nil
+	cur, err := node.persistStore.ReadCustodian(ts)
+	if err != nil {
+		return common.Zero, err
 	}
-	return poolSizeUniversal(int(dist.Batch)), nil
-}
 
-func poolSizeUniversal(batch int) common.Integer {
-	mint, pool := common.Zero, MintPool
-	for i := 0; i < batch/MintYearBatches; i++ {
-		year := pool.Div(MintYearShares)
-		mint = mint.Add(year)
-		pool = pool.Sub(year)
+	total := mintBatchTotal(int(batch))
+	gap := int(batch - prev.Batch)
+	if cur == nil && node.isMainnet() {
+		return total.Div(10).Mul(9).Mul(gap), nil
+	}
+
+	amount := total.Mul(gap)
+	if amount.Cmp(node.minMintAmount()) < 0 {
+		return common.Zero, nil
+	}
+	if !node.isMainnet() || batch < MainnetMintTransactionV3ForkBatch {
+		return amount, nil
+	}
+	if prev.Group == string(common.MintGroupUniversal) {
+		return amount, nil
+	}
+	if prev.Batch+1 != batch {
+		return common.Zero, fmt.Errorf("legacy light pool slash not continuous %d %d", prev.Batch, batch)
+	}
+	return amount.Add(PoolDivergence(int(prev.Batch))), nil
+}
+
+func (node *Node) PoolSize() (common.Integer, error) {
+	dist, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+	if err != nil {
+		return common.Zero, err
+	}
+	if dist.Group == string(common.MintGroupKernelNodeLegacy) {
+		return poolSizeLegacy(int(dist.Batch)), nil
+	}
+	return poolSizeUniversal(int(dist.Batch)), nil
+}
+
+// the distributions are keyed by batch, so a duplicate could only come from
+// a corrupted store or a buggy upgrade, and the batches are sorted
+func (node *Node) DetectDuplicateMints(from, to uint64) ([]uint64, error) {
+	var duplicates []uint64
+	counts := make(map[uint64]int)
+	for offset := from; offset <= to; {
+		mints, _, err := node.persistStore.ReadMintDistributions(offset, 500)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range mints {
+			if m.Batch < from || m.Batch > to {
+				continue
+			}
+			counts[m.Batch] += 1
+			if counts[m.Batch] == 2 {
+				duplicates = append(duplicates, m.Batch)
+			}
+		}
+		if len(mints) < 500 {
+			break
+		}
+		offset = mints[len(mints)-1].Batch + 1
+	}
+	return duplicates, nil
+}
+
+// a mint covers all the batches since the previous mint, so a batch without
+// its own distribution is not a gap when any later distribution exists, and
+// the batch 0 is never minted, thus only the batches after the last mint up
+// to the current batch are gaps, and they will be covered by the next catch
+// up mint
+func (node *Node) MintGaps(from, to uint64) ([]uint64, error) {
+	if batch := uint64(node.mintBatch(node.GraphTimestamp)); to > batch {
+		to = batch
+	}
+	if from == 0 {
+		from = 1
+	}
+	covered := from - 1
+	for offset := from; covered < to; {
+		mints, _, err := node.persistStore.ReadMintDistributions(offset, 500)
+		if err != nil {
+			return nil, err
+		}
+		if len(mints) == 0 {
+			break
+		}
+		covered = mints[len(mints)-1].Batch
+		offset = covered + 1
+	}
+	var gaps []uint64
+	for batch := covered + 1; batch <= to; batch++ {
+		gaps = append(gaps, batch)
+	}
+	return gaps, nil
+}
+
+type YearCheck struct {
+	Year     int
+	Batch    uint64
+	Expected common.Integer
+	Actual   common.Integer
+	Matched  bool
+}
+
+// the expected pool size at each completed year boundary is compared with
+// the pool minus all the mints up to the boundary batch, in the same way as
+// the pool size is computed from the last mint batch
+func (node *Node) VerifyYearBoundaries() ([]YearCheck, error) {
+	var checks []YearCheck
+	var last uint64
+	year, minted := 1, common.Zero
+	for offset := uint64(0); ; {
+		mints, _, err := node.persistStore.ReadMintDistributions(offset, 500)
+		if err != nil {
+			return nil, err
+		}
+		if len(mints) == 0 {
+			break
+		}
+		for _, m := range mints {
+			for m.Batch > uint64(year*MintYearBatches) {
+				checks = append(checks, yearBoundaryCheck(year, minted))
+				year = year + 1
+			}
+			minted = minted.Add(m.Amount)
+			last = m.Batch
+		}
+		offset = last + 1
+	}
+	for last >= uint64(year*MintYearBatches) {
+		checks = append(checks, yearBoundaryCheck(year, minted))
+		year = year + 1
+	}
+	return checks, nil
+}
+
+// the year of the batch ends at the year boundary batch, the same as
+// VerifyYearBoundaries, and all distributions since the previous boundary
+// are counted, including the legacy mints and the light pool slash
+func (node *Node) RemainingYearEmission(ts uint64) (common.Integer, error) {
+	var year int
+	if batch := node.mintBatch(ts); batch > 0 {
+		year = (batch - 1) / MintYearBatches
+	}
+	from, to := year*MintYearBatches, (year+1)*MintYearBatches
+	share := poolSizeUniversal(from).Sub(poolSizeUniversal(to))
+
+	minted := common.NewInteger(0)
+	for offset := uint64(from + 1); offset <= uint64(to); {
+		mints, _, err := node.persistStore.ReadMintDistributions(offset, 500)
+		if err != nil {
+			return common.Zero, err
+		}
+		for _, m := range mints {
+			if m.Batch <= uint64(to) && m.Amount.Sign() > 0 {
+				minted = minted.Add(m.Amount)
+			}
+		}
+		if len(mints) < 500 {
+			break
+		}
+		offset = mints[len(mints)-1].Batch + 1
+	}
+	if minted.Sign() == 0 {
+		return share, nil
+	}
+	if minted.Cmp(share) >= 0 {
+		return common.Zero, nil
+	}
+	return share.Sub(minted), nil
+}
+
+func yearBoundaryCheck(year int, minted common.Integer) YearCheck {
+	batch := year * MintYearBatches
+	check := YearCheck{
+		Year:     year,
+		Batch:    uint64(batch),
+		Expected: poolSizeUniversal(batch),
+		Actual:   MintPool,
+	}
+	if minted.Sign() > 0 {
+		check.Actual = MintPool.Sub(minted)
+	}
+	check.Matched = check.Expected.Cmp(check.Actual) == 0
+	return check
+}
+
+func (node *Node) MintBreakdown(batch uint64) (common.Integer, common.Integer, common.Integer, error) {
+	mints, txs, err := node.persistStore.ReadMintDistributions(batch, 1)
+	if err != nil {
+		return common.Zero, common.Zero, common.Zero, err
+	}
+	if len(mints) != 1 || mints[0].Batch != batch {
+		return common.Zero, common.Zero, common.Zero, fmt.Errorf("mint distribution not found %d", batch)
+	}
+	return mintTransactionBreakdown(txs[0])
+}
+
+// the MintSigner name is taken by the signer field, and the ed25519 signature
+// can not recover the key, so the signers of the nodes are tried one by one
+func (node *Node) RecoverMintSigner(batch uint64) (common.Address, error) {
+	snap, tx, err := node.readMintSnapshot(batch)
+	if err != nil {
+		return common.Address{}, err
+	}
+	for _, n := range node.nodesListByDay(snap.Timestamp, false) {
+		signed, err := mintSignedBy(tx, n.Signer)
+		if err != nil {
+			return common.Address{}, err
+		}
+		if signed {
+			return n.Signer, nil
+		}
+	}
+	return common.Address{}, fmt.Errorf("mint signer not found %d %s", batch, tx.PayloadHash())
+}
+
+// the batches are sorted, and a node signer is never reused by another node
+func (node *Node) MintsProducedBy(id crypto.Hash, from, to uint64) ([]uint64, error) {
+	var signer *common.Address
+	for _, n := range node.NodesListWithoutState(^uint64(0), false) {
+		if n.IdForNetwork == id {
+			signer = &n.Signer
+		}
+	}
+	if signer == nil {
+		return nil, fmt.Errorf("node not found %s", id)
+	}
+
+	var batches []uint64
+	for offset := from; offset <= to; {
+		mints, txs, err := node.persistStore.ReadMintDistributions(offset, 500)
+		if err != nil {
+			return nil, err
+		}
+		for i, m := range mints {
+			if m.Batch < from || m.Batch > to {
+				continue
+			}
+			signed, err := mintSignedBy(txs[i], *signer)
+			if err != nil {
+				return nil, err
+			}
+			if signed {
+				batches = append(batches, m.Batch)
+			}
+		}
+		if len(mints) < 500 {
+			break
+		}
+		offset = mints[len(mints)-1].Batch + 1
+	}
+	return batches, nil
+}
+
+func mintSignedBy(tx *common.VersionedTransaction, signer common.Address) (bool, error) {
+	if len(tx.SignaturesMap) != 1 || tx.SignaturesMap[0][0] == nil {
+		return false, fmt.Errorf("invalid mint signature %d %s", tx.Inputs[0].Mint.Batch, tx.PayloadHash())
+	}
+	sig := tx.SignaturesMap[0][0]
+	return signer.PublicSpendKey.Verify(tx.PayloadMarshal(), *sig), nil
+}
+
+func (node *Node) mintTimestamp(batch uint64) uint64 {
+	kmb, _ := node.mintWindow(int(batch))
+	return node.Epoch + batch*uint64(time.Hour*24) + uint64(kmb)*uint64(time.Hour)
+}
+
+// the kernel node outputs are matched to the accepted nodes by the output mask
+// derived from the mint seed, the work is the weighted work of the day before,
+// and the custodian and light outputs have empty node id, payee and work
+func (node *Node) ExportMintDistributionCSV(w io.Writer, from, to uint64) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"batch", "node_id", "payee", "work", "amount", "group"})
+	if err != nil {
+		return err
+	}
+
+	for offset := from; offset <= to; {
+		mints, txs, err := node.persistStore.ReadMintDistributions(offset, 100)
+		if err != nil {
+			return err
+		}
+		if len(mints) == 0 {
+			break
+		}
+		for i, m := range mints {
+			if m.Batch > to {
+				break
+			}
+			err = node.writeMintDistributionCSV(cw, m, txs[i])
+			if err != nil {
+				return err
+			}
+		}
+		offset = mints[len(mints)-1].Batch + 1
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func (node *Node) writeMintDistributionCSV(cw *csv.Writer, m *common.MintDistribution, tx *common.VersionedTransaction) error {
+	accepted, works, err := node.listBatchMintWorks(node.mintTimestamp(m.Batch))
+	if err != nil {
+		return err
+	}
+	masks := make(map[crypto.Key]*CNode)
+	for _, n := range accepted {
+		in := fmt.Sprintf("MINTKERNELNODE%d", m.Batch)
+		seed := MintSeed(n.Signer, in)
+		r := crypto.NewKeyFromSeed(seed)
+		masks[r.Public()] = n
+	}
+
+	batch := fmt.Sprint(m.Batch)
+	for _, out := range tx.Outputs {
+		row := []string{batch, "", "", "", out.Amount.String(), m.Group}
+		if n := masks[out.Mask]; n != nil {
+			w := NewNodeWork(works[n.IdForNetwork])
+			row[1] = n.IdForNetwork.String()
+			row[2] = n.Payee.String()
+			row[3] = w.Score().String()
+		}
+		err := cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// the light outputs of universal mints and the diff outputs of legacy mints
+// go to the unspendable address of the zero seed, they are not burned by the
+// protocol, but they are never in the circulating supply
+func (node *Node) CumulativeBurned(from, to uint64) (common.Integer, error) {
+	burned := common.NewInteger(0)
+	for offset := from; offset <= to; {
+		mints, txs, err := node.persistStore.ReadMintDistributions(offset, 100)
+		if err != nil {
+			return common.Zero, err
+		}
+		if len(mints) == 0 {
+			break
+		}
+		for i, m := range mints {
+			if m.Batch > to {
+				break
+			}
+			_, _, light, err := mintTransactionBreakdown(txs[i])
+			if err != nil {
+				return common.Zero, err
+			}
+			if light.Sign() > 0 {
+				burned = burned.Add(light)
+			}
+		}
+		offset = mints[len(mints)-1].Batch + 1
+	}
+	return burned, nil
+}
+
+// universal: kernel node outputs, custodian safe output, light output
+// legacy: kernel node outputs, optional unspendable diff output as light
+func mintTransactionBreakdown(tx *common.VersionedTransaction) (common.Integer, common.Integer, common.Integer, error) {
+	kernel, safe, light := common.Zero, common.Zero, common.Zero
+	if len(tx.Inputs) != 1 || tx.Inputs[0].Mint == nil {
+		return kernel, safe, light, fmt.Errorf("invalid mint transaction %s", tx.PayloadHash())
+	}
+	mint := tx.Inputs[0].Mint
+
+	outputs := tx.Outputs
+	if mint.Group == string(common.MintGroupUniversal) {
+		if len(outputs) < 2 {
+			return kernel, safe, light, fmt.Errorf("invalid universal mint outputs %d", len(outputs))
+		}
+		light = outputs[len(outputs)-1].Amount
+		safe = outputs[len(outputs)-2].Amount
+		outputs = outputs[:len(outputs)-2]
+	} else if n := len(outputs); n > 0 && outputs[n-1].Script.String() == common.NewThresholdScript(common.Operator64).String() {
+		light = outputs[n-1].Amount
+		outputs = outputs[:n-1]
+	}
+	for _, out := range outputs {
+		kernel = kernel.Add(out.Amount)
+	}
+
+	total := kernel
+	for _, v := range []common.Integer{safe, light} {
+		if v.Sign() > 0 {
+			total = total.Add(v)
+		}
+	}
+	if total.Cmp(mint.Amount) != 0 {
+		return kernel, safe, light, fmt.Errorf("malformed mint breakdown %s %s", mint.Amount, total)
+	}
+	return kernel, safe, light, nil
+}
+
+func mintBatchTotal(batch int) common.Integer {
+	pool := MintPool
+	for i := 0; i < batch/MintYearBatches; i++ {
+		pool = pool.Sub(pool.Div(MintYearShares))
+	}
+	return pool.Div(MintYearShares).Div(MintYearBatches)
+}
+
+func poolSizeUniversal(batch int) common.Integer {
+	mint, pool := common.Zero, MintPool
+	for i := 0; i < batch/MintYearBatches; i++ {
+		year := pool.Div(MintYearShares)
+		mint = mint.Add(year)
+		pool = pool.Sub(year)
 	}
 	day := pool.Div(MintYearShares).Div(MintYearBatches)
 	if count := batch % MintYearBatches; count > 0 {
@@ -279,6 +1216,50 @@ func poolSizeLegacy(batch int) common.Integer {
 	return MintPool
 }
 
+// the legacy mint only emits 90% of the universal mint, and the divergence
+// at the last legacy batch is slashed by the first universal mint
+func PoolDivergence(batch int) common.Integer {
+	return poolSizeLegacy(batch).Sub(poolSizeUniversal(batch))
+}
+
+// the first batch when the universal pool falls below the threshold,
+// or -1 if the pool stops decreasing before reaching it
+func PoolDepletionEstimate(threshold common.Integer) int {
+	if MintPool.Cmp(threshold) < 0 {
+		return 0
+	}
+	pool := MintPool
+	for year := 0; ; year++ {
+		share := pool.Div(MintYearShares)
+		if share.Div(MintYearBatches).Sign() == 0 {
+			return -1
+		}
+		begin, end := year*MintYearBatches, (year+1)*MintYearBatches
+		if poolSizeUniversal(end).Cmp(threshold) >= 0 {
+			pool = pool.Sub(share)
+			continue
+		}
+		for b := begin + 1; b <= end; b++ {
+			if poolSizeUniversal(b).Cmp(threshold) < 0 {
+				return b
+			}
+		}
+	}
+}
+
+// false if the pool never falls below half of the mint pool
+func PoolHalfLife() (time.Duration, bool) {
+	return poolDepletionDuration(MintPool.Div(2))
+}
+
+func poolDepletionDuration(threshold common.Integer) (time.Duration, bool) {
+	batches := PoolDepletionEstimate(threshold)
+	if batches < 0 {
+		return 0, false
+	}
+	return time.Duration(batches) * time.Hour * 24, true
+}
+
 func (node *Node) PledgeAmount(ts uint64) common.Integer {
 	if ts < node.Epoch {
 		return pledgeAmount(0)
@@ -287,6 +1268,24 @@ func (node *Node) PledgeAmount(ts uint64) common.Integer {
 	return pledgeAmount(time.Duration(since))
 }
 
+// the live pledge at the graph timestamp, which a node pledging now must
+// provide, and it changes at each year boundary since the epoch
+func (node *Node) CurrentJoinPledge() common.Integer {
+	return node.PledgeAmount(node.GraphTimestamp)
+}
+
+// the pledge amount only steps up at each year boundary since the epoch
+func (node *Node) PledgeScheduleNext(ts uint64) (common.Integer, time.Time, common.Integer) {
+	var since time.Duration
+	if ts > node.Epoch {
+		since = time.Duration(ts - node.Epoch)
+	}
+	year := time.Hour * 24 * time.Duration(MintYearBatches)
+	next := (since/year + 1) * year
+	change := node.EpochTime().Add(next)
+	return pledgeAmount(since), change, pledgeAmount(next)
+}
+
 func pledgeAmount(sinceEpoch time.Duration) common.Integer {
 	batch := int(sinceEpoch / 3600000000000 / 24)
 	liquidity, pool := MintLiquidity, MintPool
@@ -298,46 +1297,42 @@ func pledgeAmount(sinceEpoch time.Duration) common.Integer {
 	return liquidity.Div(MintNodeMaximum)
 }
 
-func (node *Node) buildLegacyKerneNodeMintTransaction(timestamp uint64, validateOnly bool) *common.VersionedTransaction {
-	batch, amount := node.checkLegacyMintPossibility(timestamp, validateOnly)
+func (node *Node) buildLegacyKerneNodeMintTransaction(timestamp, last uint64, validateOnly bool) *common.VersionedTransaction {
+	batch, amount := node.checkLegacyMintPossibility(timestamp, last, validateOnly)
 	if amount.Sign() <= 0 || batch <= 0 {
 		return nil
 	}
 
-	// TODO mint works should calculate according to finalized previous round, new fork required
-	if raw := TransactionMintWorkHacks[batch]; raw != "" && node.isMainnet() {
-		rt, err := hex.DecodeString(raw)
-		if err != nil {
-			panic(raw)
-		}
+	if rt, found := MintWorkHackTransaction(uint64(batch)); found && node.isMainnet() {
 		ver, err := common.UnmarshalVersionedTransaction(rt)
 		if err != nil {
-			panic(raw)
+			panic(hex.EncodeToString(rt))
 		}
 		return ver
 	}
 
 	if node.isMainnet() && batch < MainnetMintTransactionV2ForkBatch {
-		return node.buildMintTransactionV1(timestamp, validateOnly)
+		return node.buildMintTransactionV1(timestamp, last, validateOnly)
 	}
 
-	accepted := node.NodesListWithoutState(timestamp, true)
+	accepted := node.acceptedNodesByDay(timestamp)
 	mints, err := node.distributeKernelMintByWorks(accepted, amount, timestamp)
 	if err != nil {
 		logger.Printf("buildLegacyKerneNodeMintTransaction ERROR %s\n", err.Error())
 		return nil
 	}
 
-	tx := node.NewTransaction(common.XINAssetId)
+	tx := node.newMintTransaction()
 	tx.AddKernelNodeMintInputLegacy(uint64(batch), amount)
-	script := common.NewThresholdScript(1)
-	total := common.NewInteger(0)
-	for _, m := range mints {
-		in := fmt.Sprintf("MINTKERNELNODE%d", batch)
-		si := crypto.NewHash([]byte(m.Signer.String() + in))
-		seed := append(si[:], si[:]...)
-		tx.AddScriptOutput([]*common.Address{&m.Payee}, script, m.Work, seed)
-		total = total.Add(m.Work)
+	err = node.addKernelMintOutputs(tx, mints, batch)
+	if err != nil {
+		logger.Printf("buildLegacyKerneNodeMintTransaction ERROR %s\n", err.Error())
+		return nil
+	}
+	total, err := sumWorks(mints)
+	if err != nil {
+		logger.Printf("buildLegacyKerneNodeMintTransaction ERROR %s\n", err.Error())
+		return nil
 	}
 	if total.Cmp(amount) > 0 {
 		panic(fmt.Errorf("buildLegacyKerneNodeMintTransaction %s %s", amount, total))
@@ -347,91 +1342,491 @@ func (node *Node) buildLegacyKerneNodeMintTransaction(timestamp uint64, validate
 		addr := common.NewAddressFromSeed(make([]byte, 64))
 		script := common.NewThresholdScript(common.Operator64)
 		in := fmt.Sprintf("MINTKERNELNODE%dDIFF", batch)
-		si := crypto.NewHash([]byte(addr.String() + in))
-		seed := append(si[:], si[:]...)
+		seed := MintSeed(addr, in)
 		tx.AddScriptOutput([]*common.Address{&addr}, script, diff, seed)
 	}
-	return tx.AsVersioned()
+	ver := tx.AsVersioned()
+	err = checkMintOutputKeys(ver)
+	if err != nil {
+		logger.Printf("buildLegacyKerneNodeMintTransaction ERROR %s\n", err.Error())
+		return nil
+	}
+	return ver
+}
+
+// an empty allowlist doesn't restrict the custodian of the safe output, and
+// it only stops this node from proposing, the validation never checks it
+func (node *Node) custodianAllowed(custodian *common.Address) bool {
+	if len(node.AllowedCustodians) == 0 {
+		return true
+	}
+	for _, a := range node.AllowedCustodians {
+		if a.String() == custodian.String() {
+			return true
+		}
+	}
+	return false
 }
 
-func (node *Node) tryToMintKernelNodeLegacy() error {
-	signed := node.buildLegacyKerneNodeMintTransaction(node.GraphTimestamp, false)
+func (node *Node) tryToMintKernelNodeLegacy() (bool, error) {
+	signed := node.buildLegacyKerneNodeMintTransaction(node.GraphTimestamp, ^uint64(0), false)
 	if signed == nil {
-		return nil
+		return false, nil
 	}
 
-	if signed.Version == 1 {
-		err := signed.SignInputV1(node.persistStore, 0, []*common.Address{&node.Signer})
-		if err != nil {
-			return err
-		}
-	} else {
-		err := signed.SignInput(node.persistStore, 0, []*common.Address{&node.Signer})
-		if err != nil {
-			return err
-		}
+	err := node.signMintTransaction(signed)
+	if err != nil {
+		return false, err
+	}
+	err = signed.Validate(node.persistStore, false)
+	if err != nil {
+		return false, err
 	}
-	err := signed.Validate(node.persistStore, false)
+	err = node.preCommitMint(signed)
 	if err != nil {
-		return err
+		return false, err
 	}
 	err = node.persistStore.CachePutTransaction(signed)
 	if err != nil {
-		return err
-	}
-	s := &common.Snapshot{
-		Version: common.SnapshotVersionCommonEncoding,
-		NodeId:  node.IdForNetwork,
+		return false, err
 	}
-	s.AddSoleTransaction(signed.PayloadHash())
-	logger.Println("tryToMintKernelNodeLegacy", signed.PayloadHash(), hex.EncodeToString(signed.Marshal()))
-	return node.chain.AppendSelfEmpty(s)
+	return node.proposeMintSnapshot(signed)
 }
 
 func (node *Node) validateMintSnapshot(snap *common.Snapshot, tx *common.VersionedTransaction) error {
+	_, err := node.MintValidationReason(snap, tx)
+	return err
+}
+
+// the short reason code is for metrics, and the error has all the details,
+// it is safe to call concurrently when the tx payload hash is computed
+func (node *Node) MintValidationReason(snap *common.Snapshot, tx *common.VersionedTransaction) (string, error) {
+	return node.mintValidationReason(snap, tx, ^uint64(0))
+}
+
+// the last is the batch bound of the last mint distribution, and a stored
+// mint is validated with its own batch as if it were the last mint
+func (node *Node) mintValidationReason(snap *common.Snapshot, tx *common.VersionedTransaction, last uint64) (string, error) {
 	timestamp := snap.Timestamp
 	if snap.Timestamp == 0 && snap.NodeId == node.IdForNetwork {
 		timestamp = uint64(clock.Now().UnixNano())
 	}
+	if batch := node.mintBatch(timestamp); !node.isMainnet() || batch >= MainnetMintProducerForkBatch {
+		if !node.isMintProducer(snap.NodeId, timestamp) {
+			return "invalid_producer", fmt.Errorf("mint snapshot from invalid node %s at %d", snap.NodeId, timestamp)
+		}
+	}
+
+	// the legacy mint path is only possible on mainnet before the custodian,
+	// and all the historical legacy mints must still be valid there
+	if mint := tx.Inputs[0].Mint; !node.isMainnet() && mint.Group != string(common.MintGroupUniversal) {
+		return "legacy_disabled", fmt.Errorf("legacy mint disabled %s %d", mint.Group, mint.Batch)
+	}
+	err := checkMintOutputKeys(tx)
+	if err != nil {
+		return "duplicated_keys", err
+	}
 
 	var signed *common.VersionedTransaction
 	cur, err := node.persistStore.ReadCustodian(timestamp)
 	if err != nil {
-		return err
+		return "custodian_read_error", err
 	}
-	if cur == nil && node.isMainnet() {
-		signed = node.buildLegacyKerneNodeMintTransaction(timestamp, true)
-		if signed == nil {
-			return fmt.Errorf("no legacy mint available at %d", timestamp)
-		}
-	} else {
-		signed = node.buildUniversalMintTransaction(cur, timestamp, true)
-		if signed == nil {
-			return fmt.Errorf("no universal mint available at %d", timestamp)
-		}
+	legacy := cur == nil && node.isMainnet()
+	signed = node.rebuildMintTransaction(legacy, cur, timestamp, last)
+	if signed == nil && legacy {
+		return "timestamp_skip", fmt.Errorf("no legacy mint available at %d", timestamp)
+	} else if signed == nil {
+		return "timestamp_skip", fmt.Errorf("no universal mint available at %d", timestamp)
 	}
 
 	if tx.PayloadHash() != signed.PayloadHash() {
 		th := hex.EncodeToString(tx.PayloadMarshal())
 		sh := hex.EncodeToString(signed.PayloadMarshal())
-		return fmt.Errorf("malformed mint transaction at %d %s %s", timestamp, th, sh)
+		return "hash_mismatch", fmt.Errorf("malformed mint transaction at %d %s %s", timestamp, th, sh)
 	}
-	return nil
+	return "", nil
 }
 
-func (node *Node) checkUniversalMintPossibility(timestamp uint64, validateOnly bool) (int, common.Integer) {
-	if timestamp <= node.Epoch {
-		return 0, common.Zero
+// the rebuilt transactions are cached with the last mint distribution, and
+// they are shared by all validations, so they must never be modified, and
+// the transactions rebuilt before the last mint are never cached
+func (node *Node) rebuildMintTransaction(legacy bool, cur *common.CustodianUpdateRequest, timestamp, last uint64) *common.VersionedTransaction {
+	group := string(common.MintGroupUniversal)
+	if legacy {
+		group = string(common.MintGroupKernelNodeLegacy)
+	}
+	c := node.lastMintCache
+	if last != ^uint64(0) || !node.inMintTimeWindow(timestamp) {
+		c = nil
+	}
+	var key string
+	var version uint64
+	if c != nil {
+		inputs, ok := node.mintCacheInputs(timestamp)
+		if !ok {
+			c = nil
+		}
+		batch := uint64(node.mintBatch(timestamp))
+		key = fmt.Sprintf("%s:%d:%d:%s", group, batch, node.SnapshotVersion(), inputs)
+		if cur != nil && cur.Custodian != nil {
+			key = key + ":" + cur.Custodian.String()
+		}
+	}
+	if c != nil {
+		c.RLock()
+		ver := c.rebuilt[key]
+		version = c.version
+		c.RUnlock()
+		if ver != nil {
+			return ver
+		}
 	}
 
-	since := timestamp - node.Epoch
-	hours := int(since / 3600000000000)
-	batch := hours / 24
-	if batch < 1 {
+	var ver *common.VersionedTransaction
+	if legacy {
+		ver = node.buildLegacyKerneNodeMintTransaction(timestamp, last, true)
+	} else {
+		ver = node.buildUniversalMintTransaction(cur, timestamp, last, true)
+	}
+	if ver == nil || c == nil {
+		return ver
+	}
+	ver.PayloadHash()
+
+	c.Lock()
+	defer c.Unlock()
+	if c.version != version {
+		return ver
+	}
+	if c.rebuilt == nil || len(c.rebuilt) >= MintRebuiltCacheLimit {
+		c.rebuilt = make(map[string]*common.VersionedTransaction)
+	}
+	c.rebuilt[key] = ver
+	return ver
+}
+
+// the rebuilt mint is only cached when the works of the day before are
+// final, and the key covers the accepted nodes, their works and spaces
+func (node *Node) mintCacheInputs(timestamp uint64) (string, bool) {
+	accepted, works, err := node.listBatchMintWorks(timestamp)
+	if err != nil {
+		return "", false
+	}
+	cids := make([]crypto.Hash, len(accepted))
+	for i, n := range accepted {
+		cids[i] = n.IdForNetwork
+	}
+	day := timestamp / (uint64(time.Hour) * 24)
+	err = node.validateWorksFinalized(cids, node.MintConsensusThreshold(timestamp), day)
+	if err != nil {
+		return "", false
+	}
+	spaces, err := node.ListRoundSpaces(cids, day-1)
+	if err != nil {
+		return "", false
+	}
+
+	var buf []byte
+	for _, id := range cids {
+		w := works[id]
+		buf = append(buf, id[:]...)
+		buf = binary.BigEndian.AppendUint64(buf, w[0])
+		buf = binary.BigEndian.AppendUint64(buf, w[1])
+		for _, s := range spaces[id] {
+			buf = binary.BigEndian.AppendUint64(buf, s.Batch)
+			buf = binary.BigEndian.AppendUint64(buf, s.Round)
+			buf = binary.BigEndian.AppendUint64(buf, s.Duration)
+		}
+	}
+	return crypto.Blake3Hash(buf).String(), true
+}
+
+func (node *Node) isMintProducer(id crypto.Hash, timestamp uint64) bool {
+	for _, cn := range node.acceptedNodesByDay(timestamp) {
+		if cn.IdForNetwork == id {
+			return true
+		}
+	}
+	return false
+}
+
+// the stored mint is validated as if it were the latest one, so the same
+// validation of new mint snapshots applies to any historical batch
+func (node *Node) VerifyBatchMint(batch uint64) error {
+	snap, tx, err := node.readMintSnapshot(batch)
+	if err != nil {
+		return err
+	}
+	_, err = node.mintValidationReason(snap.Snapshot, tx, batch)
+	return err
+}
+
+// the outputs are rebuilt by the same builder of the distribution group,
+// so they should match the outputs of the stored mint transaction
+func (node *Node) RebuildMintOutputs(dist *common.MintDistribution, ts uint64) ([]*common.Output, error) {
+	var signed *common.VersionedTransaction
+	switch dist.Group {
+	case string(common.MintGroupUniversal):
+		cur, err := node.persistStore.ReadCustodian(ts)
+		if err != nil {
+			return nil, err
+		}
+		signed = node.buildUniversalMintTransaction(cur, ts, dist.Batch, true)
+	case string(common.MintGroupKernelNodeLegacy):
+		signed = node.buildLegacyKerneNodeMintTransaction(ts, dist.Batch, true)
+	default:
+		return nil, fmt.Errorf("invalid mint group %s", dist.Group)
+	}
+	if signed == nil {
+		return nil, fmt.Errorf("no %s mint available at %d", dist.Group, ts)
+	}
+	mint := signed.Inputs[0].Mint
+	if mint.Group != dist.Group || mint.Batch != dist.Batch || mint.Amount.Cmp(dist.Amount) != 0 {
+		return nil, fmt.Errorf("mint distribution mismatch %s %d %s %s %d %s",
+			dist.Group, dist.Batch, dist.Amount, mint.Group, mint.Batch, mint.Amount)
+	}
+	return signed.Outputs, nil
+}
+
+func (node *Node) readMintSnapshot(batch uint64) (*common.SnapshotWithTopologicalOrder, *common.VersionedTransaction, error) {
+	mints, txs, err := node.persistStore.ReadMintDistributions(batch, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(mints) != 1 || mints[0].Batch != batch {
+		return nil, nil, fmt.Errorf("mint distribution not found %d", batch)
+	}
+	tx := txs[0]
+	_, sh, err := node.persistStore.ReadTransaction(tx.PayloadHash())
+	if err != nil {
+		return nil, nil, err
+	}
+	hash, err := crypto.HashFromString(sh)
+	if err != nil {
+		return nil, nil, fmt.Errorf("mint snapshot not found %d %s %v", batch, tx.PayloadHash(), err)
+	}
+	snap, err := node.persistStore.ReadSnapshot(hash)
+	if err != nil || snap == nil {
+		return nil, nil, fmt.Errorf("mint snapshot not found %d %s %v", batch, hash, err)
+	}
+	return snap, tx, nil
+}
+
+type mintReproFork struct {
+	Name    string `json:"name"`
+	Batch   uint64 `json:"batch"`
+	Enabled bool   `json:"enabled"`
+}
+
+type mintReproNode struct {
+	Id     crypto.Hash          `json:"id"`
+	Signer common.Address       `json:"signer"`
+	Payee  common.Address       `json:"payee"`
+	Works  NodeWork             `json:"works"`
+	Spaces []*common.RoundSpace `json:"spaces"`
+}
+
+type mintReproBundle struct {
+	Network   crypto.Hash `json:"network"`
+	Epoch     uint64      `json:"epoch"`
+	Batch     uint64      `json:"batch"`
+	Timestamp uint64      `json:"timestamp"`
+	Previous  struct {
+		Batch  uint64         `json:"batch"`
+		Amount common.Integer `json:"amount"`
+	} `json:"previous"`
+	Forks     []mintReproFork  `json:"forks"`
+	Nodes     []mintReproNode  `json:"nodes"`
+	Custodian *common.Address  `json:"custodian"`
+	Domains   []common.Address `json:"domains"`
+	Expected  struct {
+		Snapshot crypto.Hash `json:"snapshot"`
+		Hash     crypto.Hash `json:"hash"`
+		Payload  string      `json:"payload"`
+	} `json:"expected"`
+}
+
+// the bundle has all the inputs to build the mint transaction of the batch
+// at its snapshot time, and the payload of the finalized mint transaction
+func (node *Node) MintReproBundle(batch uint64) ([]byte, error) {
+	snap, tx, err := node.readMintSnapshot(batch)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := snap.Timestamp
+	day := timestamp / (uint64(time.Hour) * 24)
+
+	var bundle mintReproBundle
+	bundle.Network = node.networkId
+	bundle.Epoch = node.Epoch
+	bundle.Batch = batch
+	bundle.Timestamp = timestamp
+	prev, err := node.persistStore.ReadLastMintDistribution(batch - 1)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Previous.Batch = prev.Batch
+	bundle.Previous.Amount = prev.Amount
+
+	legacy := node.isMainnet()
+	for _, f := range []struct {
+		name  string
+		batch uint64
+	}{
+		{"mainnet", 0},
+		{"legacy", 0},
+		{"period", MainnetMintPeriodForkBatch},
+		{"work-distribution", MainnetMintWorkDistributionForkBatch},
+		{"transaction-v2", MainnetMintTransactionV2ForkBatch},
+		{"transaction-v3", MainnetMintTransactionV3ForkBatch},
+		{"mint-producer", MainnetMintProducerForkBatch},
+		{"zero-output", MainnetMintZeroOutputForkBatch},
+		{"mint-threshold", MainnetMintThresholdForkBatch},
+	} {
+		enabled := node.isMainnet() && batch >= f.batch
+		if f.name == "legacy" {
+			enabled = legacy
+		}
+		bundle.Forks = append(bundle.Forks, mintReproFork{f.name, f.batch, enabled})
+	}
+
+	accepted, works, err := node.listBatchMintWorks(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	cids := make([]crypto.Hash, len(accepted))
+	for i, n := range accepted {
+		cids[i] = n.IdForNetwork
+	}
+	spaces, err := node.ListRoundSpaces(cids, day-1)
+	if err != nil {
+		return nil, err
+	}
+	bundle.Nodes = make([]mintReproNode, len(accepted))
+	for i, n := range accepted {
+		bn := &bundle.Nodes[i]
+		bn.Id = n.IdForNetwork
+		bn.Signer = n.Signer
+		bn.Payee = n.Payee
+		bn.Works = NewNodeWork(works[n.IdForNetwork])
+		bn.Spaces = spaces[n.IdForNetwork]
+	}
+
+	cur, err := node.persistStore.ReadCustodian(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		bundle.Custodian = cur.Custodian
+	}
+	for _, d := range node.persistStore.ReadDomains() {
+		bundle.Domains = append(bundle.Domains, d.Account)
+	}
+
+	bundle.Expected.Snapshot = snap.Hash
+	bundle.Expected.Hash = tx.PayloadHash()
+	bundle.Expected.Payload = hex.EncodeToString(tx.PayloadMarshal())
+	return json.Marshal(bundle)
+}
+
+// the custodian is decided by the custodian at the mint snapshot time, or
+// the first domain account, then the output is matched by the seed mask
+func (node *Node) CustodianMintOutput(batch uint64) (*common.Output, common.Integer, error) {
+	snap, tx, err := node.readMintSnapshot(batch)
+	if err != nil {
+		return nil, common.Zero, err
+	}
+	if mint := tx.Inputs[0].Mint; mint.Group != string(common.MintGroupUniversal) {
+		return nil, common.Zero, fmt.Errorf("no custodian output for %s mint %d", mint.Group, batch)
+	}
+
+	cur, err := node.persistStore.ReadCustodian(snap.Timestamp)
+	if err != nil {
+		return nil, common.Zero, err
+	}
+	var custodian common.Address
+	if cur != nil && cur.Custodian != nil {
+		custodian = *cur.Custodian
+	} else if domains := node.persistStore.ReadDomains(); len(domains) > 0 {
+		custodian = domains[0].Account
+	} else {
+		return nil, common.Zero, fmt.Errorf("no custodian for mint %d", batch)
+	}
+
+	in := fmt.Sprintf("MINTCUSTODIANACCOUNT%d", batch)
+	r := crypto.NewKeyFromSeed(MintSeed(custodian, in))
+	mask := r.Public()
+	for _, out := range tx.Outputs {
+		if out.Mask == mask {
+			return out, out.Amount, nil
+		}
+	}
+	return nil, common.Zero, fmt.Errorf("custodian output not found %d %s", batch, custodian)
+}
+
+// the last mint distribution only changes when a mint snapshot is
+// finalized, and a nil cache always reads the store, the rebuilt mint
+// transactions depend on it so they are reset together, and the version
+// keeps a rebuild racing the reset from being stored
+type mintDistributionCache struct {
+	sync.RWMutex
+	version uint64
+	dist    *common.MintDistribution
+	rebuilt map[string]*common.VersionedTransaction
+}
+
+func (node *Node) LastMintDistribution() (*common.MintDistribution, error) {
+	c := node.lastMintCache
+	if c == nil {
+		return node.persistStore.ReadLastMintDistribution(^uint64(0))
+	}
+	c.RLock()
+	dist := c.dist
+	c.RUnlock()
+	if dist != nil {
+		d := *dist
+		return &d, nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	dist, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+	if err != nil {
+		return nil, err
+	}
+	c.dist = dist
+	d := *dist
+	return &d, nil
+}
+
+func (node *Node) resetLastMintDistribution() {
+	c := node.lastMintCache
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.version += 1
+	c.dist = nil
+	c.rebuilt = nil
+}
+
+// the cache is only for the latest mint distribution
+func (node *Node) lastMintDistribution(last uint64) (*common.MintDistribution, error) {
+	if last == ^uint64(0) {
+		return node.LastMintDistribution()
+	}
+	return node.persistStore.ReadLastMintDistribution(last)
+}
+
+func (node *Node) checkUniversalMintPossibility(timestamp, last uint64, validateOnly bool) (int, common.Integer) {
+	if timestamp <= node.Epoch {
 		return 0, common.Zero
 	}
-	kmb, kme := config.KernelMintTimeBegin, config.KernelMintTimeEnd
-	if hours%24 < kmb || hours%24 > kme {
+
+	batch := node.mintBatch(timestamp)
+	if batch < 1 || !node.inMintTimeWindow(timestamp) {
 		return 0, common.Zero
 	}
 
@@ -442,7 +1837,7 @@ func (node *Node) checkUniversalMintPossibility(timestamp uint64, validateOnly b
 	pool = pool.Div(MintYearShares)
 	total := pool.Div(MintYearBatches)
 
-	dist, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+	dist, err := node.lastMintDistribution(last)
 	if err != nil {
 		logger.Verbosef("ReadLastMintDistribution ERROR %s\n", err)
 		return 0, common.Zero
@@ -453,36 +1848,37 @@ func (node *Node) checkUniversalMintPossibility(timestamp uint64, validateOnly b
 	if batch < int(dist.Batch) {
 		return 0, common.Zero
 	}
+	if batch == int(dist.Batch) && !validateOnly {
+		return 0, common.Zero
+	}
+	// the stored amount of a minted batch includes the legacy light pool slash,
+	// which the builder adds again, so the validation of a minted batch uses
+	// the amount from the previous distribution, the same as when minting
 	if batch == int(dist.Batch) {
-		if validateOnly {
-			return batch, dist.Amount
+		dist, err = node.persistStore.ReadLastMintDistribution(dist.Batch - 1)
+		if err != nil {
+			logger.Verbosef("ReadLastMintDistribution ERROR %s\n", err)
+			return 0, common.Zero
 		}
-		return 0, common.Zero
 	}
 
+	// a dust mint is skipped, and the next mint covers all the skipped batches
 	amount := total.Mul(batch - int(dist.Batch))
+	if amount.Cmp(node.minMintAmount()) < 0 {
+		return 0, common.Zero
+	}
 	logger.Verbosef("checkUniversalMintPossibility NEW %s %s %s %d %s %d\n",
 		pool, total, amount, batch, dist.Amount, dist.Batch)
 	return batch, amount
 }
 
-func (node *Node) checkLegacyMintPossibility(timestamp uint64, validateOnly bool) (int, common.Integer) {
+func (node *Node) checkLegacyMintPossibility(timestamp, last uint64, validateOnly bool) (int, common.Integer) {
 	if timestamp <= node.Epoch {
 		return 0, common.Zero
 	}
 
-	since := timestamp - node.Epoch
-	hours := int(since / 3600000000000)
-	batch := hours / 24
-	if batch < 1 {
-		return 0, common.Zero
-	}
-	kmb, kme := config.KernelMintTimeBegin, config.KernelMintTimeEnd
-	if node.isMainnet() && batch < MainnetMintPeriodForkBatch {
-		kmb = MainnetMintPeriodForkTimeBegin
-		kme = MainnetMintPeriodForkTimeEnd
-	}
-	if hours%24 < kmb || hours%24 > kme {
+	batch := node.mintBatch(timestamp)
+	if batch < 1 || !node.inMintTimeWindow(timestamp) {
 		return 0, common.Zero
 	}
 
@@ -495,7 +1891,7 @@ func (node *Node) checkLegacyMintPossibility(timestamp uint64, validateOnly bool
 	light := total.Div(10)
 	full := light.Mul(9)
 
-	dist, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+	dist, err := node.lastMintDistribution(last)
 	if err != nil {
 		logger.Verbosef("ReadLastMintDistribution ERROR %s\n", err)
 		return 0, common.Zero
@@ -506,6 +1902,8 @@ func (node *Node) checkLegacyMintPossibility(timestamp uint64, validateOnly bool
 	if batch < int(dist.Batch) {
 		return 0, common.Zero
 	}
+	// the legacy mint is never slashed, so the stored amount of a minted
+	// batch is the same as when minting, and it is used for the validation
 	if batch == int(dist.Batch) {
 		if validateOnly {
 			return batch, dist.Amount
@@ -519,14 +1917,118 @@ func (node *Node) checkLegacyMintPossibility(timestamp uint64, validateOnly bool
 	return batch, amount
 }
 
+// the signer key may be kept outside the node, e.g. in an HSM, and the
+// accounts are only used by the local signer
+type MintSigner interface {
+	SignInput(reader common.UTXOKeysReader, tx *common.VersionedTransaction, index int, accounts []*common.Address) error
+	SignMessage(account *common.Address, msg []byte) (crypto.Signature, error)
+}
+
+type localMintSigner struct{}
+
+func (localMintSigner) SignMessage(account *common.Address, msg []byte) (crypto.Signature, error) {
+	return account.PrivateSpendKey.Sign(msg), nil
+}
+
+func (localMintSigner) SignInput(reader common.UTXOKeysReader, tx *common.VersionedTransaction, index int, accounts []*common.Address) error {
+	if tx.Version == 1 {
+		return tx.SignInputV1(reader, index, accounts)
+	}
+	return tx.SignInput(reader, index, accounts)
+}
+
+// a vetoed mint is neither cached nor locked, so it is built again in the
+// next tick, and the hook may accept it then
+func (node *Node) preCommitMint(tx *common.VersionedTransaction) error {
+	if node.MintPreCommit == nil {
+		return nil
+	}
+	err := node.MintPreCommit(tx)
+	if err != nil {
+		return fmt.Errorf("mint vetoed by pre commit %s %v", tx.PayloadHash(), err)
+	}
+	return nil
+}
+
+func (node *Node) mintSigner() MintSigner {
+	if node.MintSigner != nil {
+		return node.MintSigner
+	}
+	return localMintSigner{}
+}
+
+func (node *Node) signMintTransaction(tx *common.VersionedTransaction) error {
+	return node.mintSigner().SignInput(node.persistStore, tx, 0, []*common.Address{&node.Signer})
+}
+
 type CNodeWork struct {
 	CNode
 	Work common.Integer
 }
 
+// the store keeps the works as a tuple of the produced snapshots count and
+// the signed snapshots count, and a produced snapshot weighs 1.2 signatures
+type NodeWork struct {
+	Produced uint64 `json:"produced"`
+	Signed   uint64 `json:"signed"`
+}
+
+func NewNodeWork(w [2]uint64) NodeWork {
+	return NodeWork{Produced: w[0], Signed: w[1]}
+}
+
+func (w NodeWork) tuple() [2]uint64 {
+	return [2]uint64{w.Produced, w.Signed}
+}
+
+func (w NodeWork) IsZero() bool {
+	return w.Produced == 0 && w.Signed == 0
+}
+
+func (w NodeWork) Score() common.Integer {
+	score, sign := w.ScoreBreakdown()
+	if sign.Sign() > 0 {
+		score = score.Add(sign)
+	}
+	return score
+}
+
+func (w NodeWork) ScoreBreakdown() (common.Integer, common.Integer) {
+	return common.NewInteger(w.Produced).Mul(120).Div(100), common.NewInteger(w.Signed)
+}
+
+// the score is computed from the works of the day before the batch, and it
+// is not clamped by the average works of all the nodes
+func (node *Node) WorkScoreBreakdown(id crypto.Hash, batch uint64) (fromProduced, fromSignatures common.Integer, err error) {
+	if batch < 1 {
+		return common.Zero, common.Zero, fmt.Errorf("invalid mint batch %d", batch)
+	}
+	timestamp := node.mintTimestamp(batch)
+	if !node.isMintProducer(id, timestamp) {
+		return common.Zero, common.Zero, fmt.Errorf("node %s not accepted at batch %d", id, batch)
+	}
+	prev, err := previousWorkDay(timestamp / (uint64(time.Hour) * 24))
+	if err != nil {
+		return common.Zero, common.Zero, err
+	}
+	works, err := node.persistStore.ListNodeWorks([]crypto.Hash{id}, prev)
+	if err != nil {
+		return common.Zero, common.Zero, err
+	}
+	fromProduced, fromSignatures = NewNodeWork(works[id]).ScoreBreakdown()
+	return fromProduced, fromSignatures, nil
+}
+
+type ValidatorRow struct {
+	IdForNetwork crypto.Hash
+	Payee        common.Address
+	Pledge       common.Integer
+	Works        NodeWork
+}
+
 func (node *Node) ListMintWorks(batch uint64) (map[crypto.Hash][2]uint64, error) {
-	now := node.Epoch + batch*uint64(time.Hour*24)
-	list := node.NodesListWithoutState(now, true)
+	now := node.mintTimestamp(batch)
+	list := node.acceptedNodesByDay(now)
 	cids := make([]crypto.Hash, len(list))
 	for i, n := range list {
 		cids[i] = n.IdForNetwork
@@ -536,6 +2038,80 @@ func (node *Node) ListMintWorks(batch uint64) (map[crypto.Hash][2]uint64, error)
 	return works, err
 }
 
+// the pledge is the amount of the node accept output, and the works
+// are the raw works of the day of ts, not the adjusted mint works
+func (node *Node) ValidatorTable(ts uint64) ([]ValidatorRow, error) {
+	list := node.acceptedNodesByDay(ts)
+	cids := make([]crypto.Hash, len(list))
+	for i, n := range list {
+		cids[i] = n.IdForNetwork
+	}
+	day := ts / (uint64(time.Hour) * 24)
+	works, err := node.persistStore.ListNodeWorks(cids, uint32(day))
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]ValidatorRow, len(list))
+	for i, n := range list {
+		accept, _, err := node.persistStore.ReadTransaction(n.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		if accept == nil || len(accept.Outputs) == 0 {
+			return nil, fmt.Errorf("accept transaction not available %s %s", n.IdForNetwork, n.Transaction)
+		}
+		rows[i] = ValidatorRow{
+			IdForNetwork: n.IdForNetwork,
+			Payee:        n.Payee,
+			Pledge:       accept.Outputs[0].Amount,
+			Works:        NewNodeWork(works[n.IdForNetwork]),
+		}
+	}
+	return rows, nil
+}
+
+// the batches are the days since the epoch, the same as the batch used by
+// ListRoundSpaces, and only the rounds with a space are recorded
+func (node *Node) NodeRoundSpaceBatches(id crypto.Hash) ([]uint64, error) {
+	return node.persistStore.ListNodeRoundSpaceBatches(id)
+}
+
+// the snapshot works are removed once aggregated, so the snapshots are read
+// from the graph, and the signers are recovered from the cosi signatures,
+// the work day of the batch is the same as ListMintWorks
+func (node *Node) NodeSnapshotsForBatch(id crypto.Hash, batch uint64) ([]*common.SnapshotWork, error) {
+	chain := node.getChain(id)
+	if chain == nil {
+		return nil, fmt.Errorf("chain not found %s", id)
+	}
+	last, err := node.persistStore.ReadLastSnapshotRound(id)
+	if err != nil {
+		return nil, err
+	}
+	day := node.mintTimestamp(batch) / (uint64(time.Hour) * 24)
+
+	var works []*common.SnapshotWork
+	for round := last + 1; round > 0; round-- {
+		snapshots, err := node.persistStore.ReadSnapshotsForNodeRound(id, round-1)
+		if err != nil {
+			return nil, err
+		}
+		if len(snapshots) == 0 {
+			continue
+		}
+		rd := snapshots[0].Timestamp / (uint64(time.Hour) * 24)
+		if rd > day {
+			continue
+		}
+		if rd < day {
+			break
+		}
+		works = append(chain.snapshotWorks(snapshots), works...)
+	}
+	return works, nil
+}
+
 func (node *Node) ListRoundSpaces(cids []crypto.Hash, day uint64) (map[crypto.Hash][]*common.RoundSpace, error) {
 	epoch := node.Epoch / (uint64(time.Hour) * 24)
 	spaces := make(map[crypto.Hash][]*common.RoundSpace)
@@ -567,43 +2143,220 @@ func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Int
 		panic(fmt.Errorf("invalid mint day %d %d", epoch, day))
 	}
 	if day-epoch == 0 {
-		work := base.Div(len(mints))
-		for _, m := range mints {
-			m.Work = work
-		}
-		return mints, nil
+		return distributeKernelMintEqually(mints, base), nil
+	}
+
+	prev, err := previousWorkDay(day)
+	if err != nil {
+		return nil, err
 	}
 
-	thr := int(node.ConsensusThreshold(timestamp, false))
-	err := node.validateWorksAndSpacesAggregator(cids, thr, day)
+	thr := node.MintConsensusThreshold(timestamp)
+	err = node.validateWorksAndSpacesAggregator(cids, thr, day)
 	if err != nil {
 		return nil, fmt.Errorf("distributeKernelMintByWorks not ready yet %d %v", day, err)
 	}
 
-	works, err := node.persistStore.ListNodeWorks(cids, uint32(day)-1)
+	works, err := node.persistStore.ListNodeWorks(cids, prev)
 	if err != nil {
 		return nil, err
 	}
-	spaces, err := node.ListRoundSpaces(cids, day-1)
+	if logger.Enabled(logger.VERBOSE) {
+		logger.Verbosef("distributeKernelMintByWorks works %d %s\n", day, formatMintWorks(works))
+	}
+	spaces, err := node.ListRoundSpaces(cids, uint64(prev))
 	if err != nil {
 		return nil, err
 	}
 
-	var valid int
-	var minW, maxW, totalW common.Integer
+	orphans, err := node.listOrphanWorks(accepted, timestamp, uint64(prev))
+	if err != nil {
+		logger.Printf("distributeKernelMintByWorks orphan works %d %v\n", day, err)
+	}
+	for _, id := range orphans {
+		logger.Printf("distributeKernelMintByWorks orphan works %d %s\n", day, id)
+	}
+
 	for _, m := range mints {
 		ns := spaces[m.IdForNetwork]
 		if len(ns) > 0 {
 			// TODO use this for universal mint distributions
 			logger.Printf("node spaces %s %d %d\n", m.IdForNetwork, ns[0].Batch, len(ns))
 		}
+	}
+	return distributeKernelMintByWorksMap(mints, works, base, thr, day)
+}
 
-		w := works[m.IdForNetwork]
-		m.Work = common.NewInteger(w[0]).Mul(120).Div(100)
-		sign := common.NewInteger(w[1])
-		if sign.Sign() > 0 {
-			m.Work = m.Work.Add(sign)
+// the division remainder goes to the node with the smallest id
+func distributeKernelMintEqually(mints []*CNodeWork, base common.Integer) []*CNodeWork {
+	if len(mints) == 0 {
+		return mints
+	}
+	work := base.Div(len(mints))
+	first := mints[0]
+	for _, m := range mints {
+		m.Work = work
+		if m.IdForNetwork.String() < first.IdForNetwork.String() {
+			first = m
 		}
+	}
+	first.Work = work.Add(base.Sub(work.Mul(len(mints))))
+	return mints
+}
+
+// orphan works are works recorded for the mint day by nodes not in the
+// accepted list, they are ignored by the distribution
+func (node *Node) OrphanWorks(batch uint64) ([]crypto.Hash, error) {
+	if batch < 1 {
+		return nil, fmt.Errorf("invalid mint batch %d", batch)
+	}
+	timestamp := node.mintTimestamp(batch)
+	prev, err := previousWorkDay(timestamp / (uint64(time.Hour) * 24))
+	if err != nil {
+		return nil, err
+	}
+	accepted := node.acceptedNodesByDay(timestamp)
+	return node.listOrphanWorks(accepted, timestamp, uint64(prev))
+}
+
+func (node *Node) listOrphanWorks(accepted []*CNode, timestamp, day uint64) ([]crypto.Hash, error) {
+	filter := make(map[crypto.Hash]bool)
+	for _, n := range accepted {
+		filter[n.IdForNetwork] = true
+	}
+	var cids []crypto.Hash
+	for _, n := range node.nodesListByDay(timestamp, false) {
+		if !filter[n.IdForNetwork] {
+			cids = append(cids, n.IdForNetwork)
+		}
+	}
+	if len(cids) == 0 {
+		return nil, nil
+	}
+	works, err := node.persistStore.ListNodeWorks(cids, uint32(day))
+	if err != nil {
+		return nil, err
+	}
+	var orphans []crypto.Hash
+	for _, id := range cids {
+		if w := NewNodeWork(works[id]); !w.IsZero() {
+			orphans = append(orphans, id)
+		}
+	}
+	return orphans, nil
+}
+
+// the mint distribution needs at least the threshold of valid works,
+// and it doesn't require the final consensus threshold
+//
+// a pledging node is counted about 12 hours after its pledge, which may
+// be in the middle of the mint window, so the threshold is pinned to the
+// start of the work day, and mainnet only since the fork batch to validate
+// all historical mints
+func (node *Node) MintConsensusThreshold(ts uint64) int {
+	if node.isMainnet() && node.mintBatch(ts) < MainnetMintThresholdForkBatch {
+		return node.ConsensusThreshold(ts, false)
+	}
+	start := ts / (uint64(time.Hour) * 24) * (uint64(time.Hour) * 24)
+	if start > node.Epoch {
+		ts = start
+	}
+	return node.ConsensusThreshold(ts, false)
+}
+
+// the works are sorted by node id, so the logs of different nodes can be
+// compared directly
+func formatMintWorks(works map[crypto.Hash][2]uint64) string {
+	ids := make([]crypto.Hash, 0, len(works))
+	for id := range works {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+	tuples := make([]string, len(ids))
+	for i, id := range ids {
+		w := NewNodeWork(works[id])
+		tuples[i] = fmt.Sprintf("(%s,%d,%d)", id, w.Produced, w.Signed)
+	}
+	return strings.Join(tuples, " ")
+}
+
+// the works of a mint are from the day before, and the unsigned day must
+// never underflow to a far future day
+func previousWorkDay(day uint64) (uint32, error) {
+	if day < 1 {
+		return 0, fmt.Errorf("invalid work day %d", day)
+	}
+	return uint32(day - 1), nil
+}
+
+// the accepted nodes at the mint timestamp, with their works of the day
+// before, which are the works distributed by the mint of the timestamp
+func (node *Node) listBatchMintWorks(timestamp uint64) ([]*CNode, map[crypto.Hash][2]uint64, error) {
+	prev, err := previousWorkDay(timestamp / (uint64(time.Hour) * 24))
+	if err != nil {
+		return nil, nil, err
+	}
+	accepted := node.acceptedNodesByDay(timestamp)
+	cids := make([]crypto.Hash, len(accepted))
+	for i, n := range accepted {
+		cids[i] = n.IdForNetwork
+	}
+	works, err := node.persistStore.ListNodeWorks(cids, prev)
+	return accepted, works, err
+}
+
+// the threshold is 2/3 of the accepted nodes, because a hypothetical list
+// has no timestamp to decide the consensus threshold
+func (node *Node) DistributeForWorks(accepted []*CNode, works map[crypto.Hash][2]uint64, base common.Integer) ([]*CNodeWork, error) {
+	mints := make([]*CNodeWork, len(accepted))
+	for i, n := range accepted {
+		mints[i] = &CNodeWork{CNode: *n}
+	}
+	thr := len(accepted)*2/3 + 1
+	return distributeKernelMintByWorksMap(mints, works, base, thr, 0)
+}
+
+func distributeKernelMintByWorksMap(mints []*CNodeWork, works map[crypto.Hash][2]uint64, base common.Integer, thr int, day uint64) ([]*CNodeWork, error) {
+	avg, err := averageKernelMintWorks(mints, works, thr, day)
+	if err != nil {
+		return nil, err
+	}
+
+	totalW := adjustKernelMintWorks(mints, avg)
+	for _, m := range mints {
+		rat := m.Work.Ration(totalW)
+		m.Work = rat.Product(base)
+	}
+	return mints, nil
+}
+
+func adjustKernelMintWorks(mints []*CNodeWork, avg common.Integer) common.Integer {
+	totalW := common.NewInteger(0)
+	for _, m := range mints {
+		switch clampKernelMintWork(m.Work, avg) {
+		case 1:
+			m.Work = avg.Mul(2)
+		case -1:
+			m.Work = avg.Div(7)
+		default:
+			if m.Work.Cmp(avg) >= 0 {
+				m.Work = m.Work.Div(6).Add(avg.Mul(5).Div(6))
+			}
+		}
+		totalW = totalW.Add(m.Work)
+	}
+	return totalW
+}
+
+// the works of the mints are set to the raw works, and the average
+// excludes the minimum and maximum works
+func averageKernelMintWorks(mints []*CNodeWork, works map[crypto.Hash][2]uint64, thr int, day uint64) (common.Integer, error) {
+	var valid int
+	var minW, maxW, totalW common.Integer
+	for _, m := range mints {
+		m.Work = NewNodeWork(works[m.IdForNetwork]).Score()
 		if m.Work.Sign() == 0 {
 			continue
 		}
@@ -619,35 +2372,382 @@ func (node *Node) distributeKernelMintByWorks(accepted []*CNode, base common.Int
 		totalW = totalW.Add(m.Work)
 	}
 	if valid < thr {
-		return nil, fmt.Errorf("distributeKernelMintByWorks not valid %d %d %d %d",
+		return common.Zero, fmt.Errorf("distributeKernelMintByWorks not valid day %d nodes %d threshold %d valid %d",
 			day, len(mints), thr, valid)
 	}
 
 	totalW = totalW.Sub(minW).Sub(maxW)
 	avg := totalW.Div(valid - 2)
 	if avg.Sign() == 0 {
-		return nil, fmt.Errorf("distributeKernelMintByWorks not valid %d %d %d %d",
+		return common.Zero, fmt.Errorf("distributeKernelMintByWorks not valid day %d nodes %d threshold %d valid %d",
 			day, len(mints), thr, valid)
 	}
+	return avg, nil
+}
+
+// 1 for the works capped to 2*avg, -1 for the works raised to avg/7
+func clampKernelMintWork(work, avg common.Integer) int {
+	if work.Cmp(avg.Mul(7)) >= 0 {
+		return 1
+	}
+	if work.Cmp(avg) < 0 && work.Cmp(avg.Div(7)) <= 0 {
+		return -1
+	}
+	return 0
+}
 
-	totalW = common.NewInteger(0)
-	upper, lower := avg.Mul(7), avg.Div(7)
+// the classification uses the same works and threshold as the mint
+// distribution of the batch
+func (node *Node) MintClampClassification(batch uint64) (high, mid, low []crypto.Hash, err error) {
+	mints, _, avg, err := node.averageBatchMintWorks(batch)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 	for _, m := range mints {
-		if m.Work.Cmp(upper) >= 0 {
-			m.Work = avg.Mul(2)
-		} else if m.Work.Cmp(avg) >= 0 {
-			m.Work = m.Work.Div(6).Add(avg.Mul(5).Div(6))
-		} else if m.Work.Cmp(lower) <= 0 {
-			m.Work = avg.Div(7)
+		switch clampKernelMintWork(m.Work, avg) {
+		case 1:
+			high = append(high, m.IdForNetwork)
+		case -1:
+			low = append(low, m.IdForNetwork)
+		default:
+			mid = append(mid, m.IdForNetwork)
 		}
-		totalW = totalW.Add(m.Work)
 	}
+	return high, mid, low, nil
+}
 
+// the slopes are the reward of one more work for a node in the region, while
+// the total adjusted works are assumed unchanged, i.e. base/total below the
+// average, and base/(6*total) from the average to 7 times the average
+func (node *Node) RewardElasticity(batch uint64) (belowAvgSlope, aboveAvgSlope common.RationalNumber, err error) {
+	mints, _, avg, err := node.averageBatchMintWorks(batch)
+	if err != nil {
+		return belowAvgSlope, aboveAvgSlope, err
+	}
+	total := adjustKernelMintWorks(mints, avg)
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+	return base.Ration(total), base.Ration(total.Mul(6)), nil
+}
+
+type MintAuditRow struct {
+	NodeId      crypto.Hash
+	Payee       common.Address
+	RawWork     NodeWork
+	ClampedWork common.Integer
+	Ration      common.RationalNumber
+	Amount      common.Integer
+}
+
+// the rows expand the kernel distribution of the daily universal mint of the
+// batch, the ration is the clamped work of the total clamped works
+func (node *Node) MintAuditTable(batch uint64) ([]MintAuditRow, error) {
+	mints, works, avg, err := node.averageBatchMintWorks(batch)
+	if err != nil {
+		return nil, err
+	}
+	total := adjustKernelMintWorks(mints, avg)
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+
+	rows := make([]MintAuditRow, len(mints))
+	for i, m := range mints {
+		ration := m.Work.Ration(total)
+		rows[i] = MintAuditRow{
+			NodeId:      m.IdForNetwork,
+			Payee:       m.Payee,
+			RawWork:     NewNodeWork(works[m.IdForNetwork]),
+			ClampedWork: m.Work,
+			Ration:      ration,
+			Amount:      ration.Product(base),
+		}
+	}
+	return rows, nil
+}
+
+func (node *Node) averageBatchMintWorks(batch uint64) ([]*CNodeWork, map[crypto.Hash][2]uint64, common.Integer, error) {
+	if batch < 1 {
+		return nil, nil, common.Zero, fmt.Errorf("invalid mint batch %d", batch)
+	}
+	timestamp := node.mintTimestamp(batch)
+	day := timestamp / (uint64(time.Hour) * 24)
+	accepted, works, err := node.listBatchMintWorks(timestamp)
+	if err != nil {
+		return nil, nil, common.Zero, err
+	}
+	mints := make([]*CNodeWork, len(accepted))
+	for i, n := range accepted {
+		mints[i] = &CNodeWork{CNode: *n}
+	}
+
+	thr := node.MintConsensusThreshold(timestamp)
+	avg, err := averageKernelMintWorks(mints, works, thr, day)
+	return mints, works, avg, err
+}
+
+// the new nodes are assumed to have the average works of the current nodes,
+// and the modified set is distributed with its own 2/3 threshold
+func (node *Node) PreviewNodeSetChange(batch uint64, add, remove []*CNode) (before, after map[crypto.Hash]common.Integer, err error) {
+	if batch < 1 {
+		return nil, nil, fmt.Errorf("invalid mint batch %d", batch)
+	}
+	timestamp := node.mintTimestamp(batch)
+	day := timestamp / (uint64(time.Hour) * 24)
+	accepted, works, err := node.listBatchMintWorks(timestamp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+	mints := make([]*CNodeWork, len(accepted))
+	for i, n := range accepted {
+		mints[i] = &CNodeWork{CNode: *n}
+	}
+	mints, err = distributeKernelMintByWorksMap(mints, works, base, node.MintConsensusThreshold(timestamp), day)
+	if err != nil {
+		return nil, nil, err
+	}
+	before = make(map[crypto.Hash]common.Integer)
 	for _, m := range mints {
-		rat := m.Work.Ration(totalW)
-		m.Work = rat.Product(base)
+		before[m.IdForNetwork] = m.Work
 	}
-	return mints, nil
+
+	var valid, lead, sign uint64
+	for _, tw := range works {
+		if w := NewNodeWork(tw); !w.IsZero() {
+			valid, lead, sign = valid+1, lead+w.Produced, sign+w.Signed
+		}
+	}
+	if valid == 0 {
+		return nil, nil, fmt.Errorf("no valid works for batch %d", batch)
+	}
+	removed := make(map[crypto.Hash]bool)
+	for _, n := range remove {
+		removed[n.IdForNetwork] = true
+	}
+	var set []*CNode
+	for _, n := range accepted {
+		if !removed[n.IdForNetwork] {
+			set = append(set, n)
+		}
+	}
+	for _, n := range add {
+		if NewNodeWork(works[n.IdForNetwork]).IsZero() {
+			works[n.IdForNetwork] = NodeWork{Produced: lead / valid, Signed: sign / valid}.tuple()
+		}
+		set = append(set, n)
+	}
+	mints, err = node.DistributeForWorks(set, works, base)
+	if err != nil {
+		return nil, nil, err
+	}
+	after = make(map[crypto.Hash]common.Integer)
+	for _, m := range mints {
+		after[m.IdForNetwork] = m.Work
+	}
+	return before, after, nil
+}
+
+func (node *Node) DiffMintDistribution(batch uint64, otherWorks map[crypto.Hash][2]uint64) (map[crypto.Hash][2]common.Integer, error) {
+	if batch < 1 {
+		return nil, fmt.Errorf("invalid mint batch %d", batch)
+	}
+	timestamp := node.mintTimestamp(batch)
+	day := timestamp / (uint64(time.Hour) * 24)
+	accepted, works, err := node.listBatchMintWorks(timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+	thr := node.MintConsensusThreshold(timestamp)
+	distribute := func(works map[crypto.Hash][2]uint64) ([]*CNodeWork, error) {
+		mints := make([]*CNodeWork, len(accepted))
+		for i, n := range accepted {
+			mints[i] = &CNodeWork{CNode: *n}
+		}
+		return distributeKernelMintByWorksMap(mints, works, base, thr, day)
+	}
+	local, err := distribute(works)
+	if err != nil {
+		return nil, fmt.Errorf("DiffMintDistribution local %v", err)
+	}
+	other, err := distribute(otherWorks)
+	if err != nil {
+		return nil, fmt.Errorf("DiffMintDistribution other %v", err)
+	}
+
+	diffs := make(map[crypto.Hash][2]common.Integer)
+	for i, m := range local {
+		if o := other[i]; m.Work.Cmp(o.Work) != 0 {
+			diffs[m.IdForNetwork] = [2]common.Integer{m.Work, o.Work}
+		}
+	}
+	return diffs, nil
+}
+
+// the projection uses the works aggregated so far, so it may still change
+// before the mint, the signature is made by the mint signer of the node
+func (node *Node) NextMintReceipt(id crypto.Hash) (uint64, common.Integer, crypto.Signature, error) {
+	var sig crypto.Signature
+	if !node.hasSigner() {
+		return 0, common.Zero, sig, fmt.Errorf("no mint signer %s", node.IdForNetwork)
+	}
+	dist, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+	if err != nil {
+		return 0, common.Zero, sig, err
+	}
+	batch := uint64(node.mintBatch(node.GraphTimestamp))
+	if batch <= dist.Batch {
+		batch = dist.Batch + 1
+	}
+
+	amount := mintBatchTotal(int(batch)).Mul(int(batch - dist.Batch))
+	work, err := node.projectNodeMint(id, batch, amount.Div(10).Mul(5))
+	if err != nil {
+		return 0, common.Zero, sig, err
+	}
+	msg := MintReceiptMessage(id, batch, work)
+	sig, err = node.mintSigner().SignMessage(&node.Signer, msg)
+	if err != nil {
+		return 0, common.Zero, sig, err
+	}
+	return batch, work, sig, nil
+}
+
+// the node kernel mint of the batch base by the works of the day before
+func (node *Node) projectNodeMint(id crypto.Hash, batch uint64, base common.Integer) (common.Integer, error) {
+	timestamp := node.mintTimestamp(batch)
+	day := timestamp / (uint64(time.Hour) * 24)
+	accepted, works, err := node.listBatchMintWorks(timestamp)
+	if err != nil {
+		return common.Zero, err
+	}
+	mints := make([]*CNodeWork, len(accepted))
+	for i, n := range accepted {
+		mints[i] = &CNodeWork{CNode: *n}
+	}
+
+	thr := node.MintConsensusThreshold(timestamp)
+	mints, err = distributeKernelMintByWorksMap(mints, works, base, thr, day)
+	if err != nil {
+		return common.Zero, err
+	}
+	for _, m := range mints {
+		if m.IdForNetwork == id {
+			return m.Work, nil
+		}
+	}
+	return common.Zero, fmt.Errorf("node not accepted for mint %s %d", id, batch)
+}
+
+// the share of the node in the next mint is assumed to continue, and each
+// day is the kernel part of the daily universal mint on the pool curve
+func (node *Node) ProjectNodeEarnings(id crypto.Hash, days int) (common.Integer, error) {
+	if days < 1 {
+		return common.Zero, fmt.Errorf("invalid projection days %d", days)
+	}
+	batch := uint64(node.mintBatch(node.GraphTimestamp))
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+	work, err := node.projectNodeMint(id, batch, base)
+	if err != nil {
+		return common.Zero, err
+	}
+	share := work.Ration(base)
+
+	total := common.NewInteger(0)
+	for i := 0; i < days; i++ {
+		daily := mintBatchTotal(int(batch) + i).Div(10).Mul(5)
+		if amount := share.Product(daily); amount.Sign() > 0 {
+			total = total.Add(amount)
+		}
+	}
+	return total, nil
+}
+
+// the works of a day are minted by the next batch, and each day is rebuilt
+// as the audit table, so a day when the node is not accepted counts as zero,
+// the deviation is computed in the integer units and rounded down
+func (node *Node) RewardVariance(id crypto.Hash, fromDay, toDay uint32) (mean, stddev common.Integer, err error) {
+	epoch := node.Epoch / (uint64(time.Hour) * 24)
+	if toDay < fromDay || uint64(fromDay) < epoch {
+		return common.Zero, common.Zero, fmt.Errorf("invalid reward days %d %d", fromDay, toDay)
+	}
+
+	amounts := make([]common.Integer, 0, toDay-fromDay+1)
+	total := common.NewInteger(0)
+	for day := uint64(fromDay); day <= uint64(toDay); day++ {
+		rows, err := node.MintAuditTable(day + 1 - epoch)
+		if err != nil {
+			return common.Zero, common.Zero, err
+		}
+		amount := common.Zero
+		for _, r := range rows {
+			if r.NodeId == id {
+				amount = r.Amount
+			}
+		}
+		if amount.Sign() > 0 {
+			total = total.Add(amount)
+		}
+		amounts = append(amounts, amount)
+	}
+	mean = total.Div(len(amounts))
+
+	unit := common.NewIntegerFromString("0.00000001")
+	variance := new(big.Int)
+	for _, a := range amounts {
+		diff := integerDistance(a, mean)
+		if diff.Sign() == 0 {
+			continue
+		}
+		d := new(big.Int).SetUint64(diff.Count(unit))
+		variance.Add(variance, d.Mul(d, d))
+	}
+	variance.Div(variance, big.NewInt(int64(len(amounts))))
+	units := variance.Sqrt(variance)
+	if units.Sign() == 0 {
+		return mean, common.Zero, nil
+	}
+	return mean, unit.Mul(int(units.Int64())), nil
+}
+
+func integerDistance(x, y common.Integer) common.Integer {
+	if x.Cmp(y) < 0 {
+		x, y = y, x
+	}
+	if y.Sign() == 0 {
+		return x
+	}
+	if x.Cmp(y) == 0 {
+		return common.Zero
+	}
+	return x.Sub(y)
+}
+
+// the avg of a day is the trimmed mean of the works minted by the next batch,
+// the same as RewardVariance, and the average is of all the days
+func (node *Node) NetworkAverageWork(fromDay, toDay uint32) (common.Integer, error) {
+	epoch := node.Epoch / (uint64(time.Hour) * 24)
+	if toDay < fromDay || uint64(fromDay) < epoch {
+		return common.Zero, fmt.Errorf("invalid work days %d %d", fromDay, toDay)
+	}
+
+	total := common.NewInteger(0)
+	for day := uint64(fromDay); day <= uint64(toDay); day++ {
+		_, _, avg, err := node.averageBatchMintWorks(day + 1 - epoch)
+		if err != nil {
+			return common.Zero, err
+		}
+		total = total.Add(avg)
+	}
+	return total.Div(int(toDay-fromDay) + 1), nil
+}
+
+// the tag separates the receipt from any other message signed by the same
+// signer key, e.g. a snapshot or a transaction
+func MintReceiptMessage(id crypto.Hash, batch uint64, amount common.Integer) []byte {
+	msg := append([]byte("MINTRECEIPT"), id[:]...)
+	msg = binary.BigEndian.AppendUint64(msg, batch)
+	return append(msg, []byte(amount.String())...)
 }
 
 func (node *Node) validateWorksAndSpacesAggregator(cids []crypto.Hash, thr int, day uint64) error {
@@ -685,3 +2785,51 @@ func (node *Node) validateWorksAndSpacesAggregator(cids []crypto.Hash, thr int,
 
 	return nil
 }
+
+// the finalized works depend on the local final rounds of the chains and the
+// local work offsets, so they are only waited for before proposing, and the
+// validation never checks them
+func (node *Node) checkWorksFinalizedBeforeProposal(timestamp uint64) error {
+	epoch := node.Epoch / (uint64(time.Hour) * 24)
+	day := timestamp / (uint64(time.Hour) * 24)
+	if day <= epoch {
+		return nil
+	}
+	accepted := node.acceptedNodesByDay(timestamp)
+	cids := make([]crypto.Hash, len(accepted))
+	for i, n := range accepted {
+		cids[i] = n.IdForNetwork
+	}
+	return node.validateWorksFinalized(cids, node.MintConsensusThreshold(timestamp), day)
+}
+
+// the works of day-1 are final only when the final round of a chain has
+// started in the current day, and the aggregator has gone past that round
+func (node *Node) validateWorksFinalized(cids []crypto.Hash, thr int, day uint64) error {
+	offsets, err := node.persistStore.ListWorkOffsets(cids)
+	if err != nil {
+		return err
+	}
+
+	finalized := 0
+	begin := day * uint64(time.Hour) * 24
+	for _, id := range cids {
+		chain := node.getChain(id)
+		if chain == nil || chain.State == nil {
+			continue
+		}
+		cache, final := chain.StateCopy()
+		if cache.Number <= final.Number || final.Start < begin {
+			continue
+		}
+		if offsets[id] < final.Number {
+			continue
+		}
+		finalized += 1
+	}
+	if finalized < thr {
+		return fmt.Errorf("validateWorksFinalized works not final yet %d %d %d %d",
+			day, len(cids), finalized, thr)
+	}
+	return nil
+}
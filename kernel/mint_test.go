@@ -1,16 +1,25 @@
 package kernel
 
 import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/kernel/internal"
 	"github.com/MixinNetwork/mixin/kernel/internal/clock"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/dgraph-io/badger/v4"
 	"github.com/stretchr/testify/require"
 )
 
@@ -37,6 +46,44 @@ func TestPledgeAmount(t *testing.T) {
 	}
 }
 
+func TestPledgeScheduleNext(t *testing.T) {
+	require := require.New(t)
+
+	epoch := time.Date(2019, 2, 28, 0, 0, 0, 0, time.UTC)
+	node := &Node{Epoch: uint64(epoch.UnixNano())}
+	for _, c := range []struct {
+		ts      time.Time
+		current string
+		next    time.Time
+		amount  string
+	}{
+		{epoch.Add(-time.Hour), "10000", time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC), "11000"},
+		{epoch, "10000", time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC), "11000"},
+		{time.Date(2020, 2, 27, 23, 0, 0, 0, time.UTC), "10000", time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC), "11000"},
+		{time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC), "11000", time.Date(2021, 2, 27, 0, 0, 0, 0, time.UTC), "11900"},
+		{time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC), "13439", time.Date(2024, 2, 27, 0, 0, 0, 0, time.UTC), "14095.1"},
+	} {
+		current, next, amount := node.PledgeScheduleNext(uint64(c.ts.UnixNano()))
+		require.Equal(common.NewIntegerFromString(c.current), current)
+		require.Equal(c.next, next)
+		require.Equal(common.NewIntegerFromString(c.amount), amount)
+		require.Equal(node.PledgeAmount(uint64(c.ts.UnixNano())), current)
+		require.Equal(node.PledgeAmount(uint64(next.UnixNano())), amount)
+	}
+}
+
+func TestCurrentJoinPledge(t *testing.T) {
+	require := require.New(t)
+
+	epoch := time.Date(2019, 2, 28, 0, 0, 0, 0, time.UTC)
+	node := &Node{Epoch: uint64(epoch.UnixNano())}
+	node.GraphTimestamp = uint64(time.Date(2020, 2, 27, 23, 0, 0, 0, time.UTC).UnixNano())
+	require.Equal(common.NewIntegerFromString("10000"), node.CurrentJoinPledge())
+	node.GraphTimestamp = uint64(time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC).UnixNano())
+	require.Equal(common.NewIntegerFromString("11000"), node.CurrentJoinPledge())
+	require.Equal(node.PledgeAmount(node.GraphTimestamp), node.CurrentJoinPledge())
+}
+
 func TestPoolSize(t *testing.T) {
 	require := require.New(t)
 
@@ -47,6 +94,26 @@ func TestPoolSize(t *testing.T) {
 	require.Equal(common.NewIntegerFromString("449876.71232877"), poolSizeUniversal(366))
 }
 
+func TestPoolDepletion(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(0, PoolDepletionEstimate(common.NewInteger(500001)))
+	require.Equal(1, PoolDepletionEstimate(common.NewInteger(500000)))
+	require.Equal(366, PoolDepletionEstimate(common.NewInteger(450000)))
+	require.Equal(-1, PoolDepletionEstimate(common.Zero))
+
+	batches := PoolDepletionEstimate(MintPool.Div(2))
+	require.Equal(2406, batches)
+	life, ok := PoolHalfLife()
+	require.True(ok)
+	require.Equal(time.Duration(batches)*time.Hour*24, life)
+	life, ok = poolDepletionDuration(common.Zero)
+	require.False(ok)
+	require.Equal(time.Duration(0), life)
+	require.True(poolSizeUniversal(batches).Cmp(MintPool.Div(2)) < 0)
+	require.True(poolSizeUniversal(batches-1).Cmp(MintPool.Div(2)) >= 0)
+}
+
 func TestPoolSizeLegacy(t *testing.T) {
 	require := require.New(t)
 
@@ -57,6 +124,124 @@ func TestPoolSizeLegacy(t *testing.T) {
 	require.Equal(common.NewIntegerFromString("454889.04109592"), poolSizeLegacy(366))
 }
 
+func TestPoolDivergence(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(0, PoolDivergence(0).Sign())
+	require.Equal(common.NewInteger(5000), PoolDivergence(365))
+	for _, batch := range []int{10, 366, 1616} {
+		divergence := PoolDivergence(batch)
+		require.True(divergence.Sign() > 0)
+		require.Equal(poolSizeLegacy(batch), poolSizeUniversal(batch).Add(divergence))
+	}
+}
+
+func TestMintTimeWindow(t *testing.T) {
+	require := require.New(t)
+
+	epoch := uint64(time.Date(2019, 2, 28, 0, 0, 0, 0, time.UTC).UnixNano())
+	node := &Node{Epoch: epoch}
+	require.False(node.inMintTimeWindow(epoch))
+	node.GraphTimestamp = epoch + uint64(time.Hour)*24*100
+	kmb, kme := node.MintWindow()
+	require.Equal(7, kmb)
+	require.Equal(9, kme)
+	for h, in := range map[int]bool{6: false, 7: true, 8: true, 9: true, 10: false, 23: false} {
+		ts := epoch + uint64(time.Duration(24*100+h)*time.Hour)
+		require.Equal(in, node.inMintTimeWindow(ts), h)
+	}
+}
+
+func TestUniversalMintTimeBoundaries(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	hour := uint64(time.Hour)
+	day := node.Epoch + 1617*24*hour
+	for ts, in := range map[uint64]bool{
+		day:                        false,
+		day + 7*hour - 1:           false,
+		day + 7*hour:               true,
+		day + 8*hour:               true,
+		day + 9*hour:               true,
+		day + 10*hour - 1:          true,
+		day + 10*hour:              false,
+		day + 24*hour - 1:          false,
+		day - 24*hour + 7*hour - 1: false,
+	} {
+		batch, amount := node.checkUniversalMintPossibility(ts, ^uint64(0), false)
+		require.Equal(in, node.inMintTimeWindow(ts), ts)
+		if !in {
+			require.Equal(0, batch, ts)
+			require.Equal(common.Zero, amount)
+			continue
+		}
+		require.Equal(1617, batch, ts)
+		require.Equal(mintBatchTotal(1617).Mul(1617), amount)
+	}
+
+	batch, _ := node.checkUniversalMintPossibility(day+24*hour+7*hour, ^uint64(0), false)
+	require.Equal(1618, batch)
+	batch, _ = node.checkUniversalMintPossibility(node.Epoch+7*hour, ^uint64(0), false)
+	require.Equal(0, batch)
+	batch, _ = node.checkUniversalMintPossibility(node.Epoch+24*hour+7*hour, ^uint64(0), false)
+	require.Equal(1, batch)
+}
+
+func TestMintPossibilityMintedBatch(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+	light := common.NewAddressFromSeed(make([]byte, 64))
+	script := common.NewThresholdScript(common.Operator64)
+
+	ts := node.mintTimestamp(1500)
+	batch, amount := node.checkLegacyMintPossibility(ts, ^uint64(0), false)
+	require.Equal(1500, batch)
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(uint64(batch), amount)
+	tx.AddScriptOutput([]*common.Address{&light}, script, amount, MintSeed(light, "MINTPOSSIBILITY1500"))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	batch, minted := node.checkLegacyMintPossibility(ts, ^uint64(0), false)
+	require.Equal(0, batch)
+	require.Equal(0, minted.Sign())
+	batch, minted = node.checkLegacyMintPossibility(ts, ^uint64(0), true)
+	require.Equal(1500, batch)
+	require.Equal(amount, minted)
+
+	ts = node.Epoch + 1501*uint64(time.Hour*24) + 8*uint64(time.Hour)
+	batch, amount = node.checkUniversalMintPossibility(ts, ^uint64(0), false)
+	require.Equal(1501, batch)
+	require.Equal(mintBatchTotal(1501), amount)
+	slashed := amount.Add(PoolDivergence(1500))
+	tx = common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(uint64(batch), slashed)
+	tx.AddScriptOutput([]*common.Address{&light}, script, slashed, MintSeed(light, "MINTPOSSIBILITY1501"))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	batch, minted = node.checkUniversalMintPossibility(ts, ^uint64(0), false)
+	require.Equal(0, batch)
+	require.Equal(0, minted.Sign())
+	batch, minted = node.checkUniversalMintPossibility(ts, ^uint64(0), true)
+	require.Equal(1501, batch)
+	require.Equal(amount, minted)
+}
+
 func TestUniversalMintTransaction(t *testing.T) {
 	require := require.New(t)
 	logger.SetLevel(0)
@@ -152,7 +337,7 @@ func TestUniversalMintTransaction(t *testing.T) {
 
 	timestamp = uint64(clock.Now().UnixNano())
 	cur := &common.CustodianUpdateRequest{Custodian: &custodian}
-	versioned = node.buildUniversalMintTransaction(cur, timestamp, false)
+	versioned = node.buildUniversalMintTransaction(cur, timestamp, ^uint64(0), false)
 	require.NotNil(versioned)
 	amount = common.NewIntegerFromString("18686.95342732")
 	mint := versioned.Inputs[0].Mint
@@ -176,6 +361,285 @@ func TestUniversalMintTransaction(t *testing.T) {
 	require.Equal(common.NewIntegerFromString("44.93835604"), kernel)
 	require.Equal(common.NewIntegerFromString("35.95068492"), safe)
 	require.Equal(common.NewIntegerFromString("18606.06438636"), light)
+	require.Equal(amount, kernel.Add(safe).Add(light))
+
+	domains := node.persistStore.ReadDomains()
+	require.Len(domains, 1)
+	fallback := node.buildUniversalMintTransaction(nil, timestamp, ^uint64(0), false)
+	require.NotNil(fallback)
+	require.NotEqual(versioned.PayloadHash(), fallback.PayloadHash())
+	malformed := node.buildUniversalMintTransaction(&common.CustodianUpdateRequest{}, timestamp, ^uint64(0), false)
+	require.NotNil(malformed)
+	require.Equal(fallback.PayloadHash(), malformed.PayloadHash())
+	node.AllowedCustodians = []common.Address{domains[0].Account}
+	disallowed := node.buildUniversalMintTransaction(cur, timestamp, ^uint64(0), false)
+	require.NotNil(disallowed)
+	require.Equal(versioned.PayloadHash(), disallowed.PayloadHash())
+	node.GraphTimestamp = timestamp
+	appended, err := node.tryToMintUniversal(cur)
+	require.Nil(err)
+	require.False(appended)
+	proposal, err := node.persistStore.ReadLastMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(0), proposal)
+	node.AllowedCustodians = append(node.AllowedCustodians, custodian)
+	require.True(node.custodianAllowed(&custodian))
+}
+
+func TestUniversalLightAmount(t *testing.T) {
+	require := require.New(t)
+
+	amount, kernel, safe := common.NewInteger(100), common.NewInteger(50), common.NewInteger(40)
+	remainder := common.NewIntegerFromString("0.5")
+	light, err := universalLightAmount(amount, kernel, safe, remainder, common.NewIntegerFromString("89.5"))
+	require.Nil(err)
+	require.Equal("10.50000000", light.String())
+	light, err = universalLightAmount(amount, kernel, safe, common.Zero, common.NewInteger(90))
+	require.Nil(err)
+	require.Equal("10.00000000", light.String())
+
+	_, err = universalLightAmount(amount, kernel, safe, common.Zero, common.NewInteger(101))
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid universal light amount")
+	_, err = universalLightAmount(amount, kernel, safe, common.Zero, amount)
+	require.NotNil(err)
+	_, err = universalLightAmount(amount, kernel, common.NewInteger(60), common.Zero, common.NewInteger(90))
+	require.NotNil(err)
+}
+
+func TestMintSeed(t *testing.T) {
+	require := require.New(t)
+
+	light := common.NewAddressFromSeed(make([]byte, 64))
+	require.Equal("XIN8b7CsqwqaBP7576hvWzo7uDgbU9TB5KGU4jdgYpQTi2qrQGpBtrW49ENQiLGNrYU45e2wwKRD7dEUPtuaJYps2jbR4dH", light.String())
+	for tag, seed := range map[string]string{
+		"MINTKERNELNODE895":        "f86a577b693078d1ea2cd607184a343aea195d3755846fb46babf6e716a7195f",
+		"MINTCUSTODIANACCOUNT1617": "37cdd45c6a31b0da76db35d7eab14e07213c1bffa4d954bcd300df4b8cad408e",
+		"MINTLIGHTACCOUNT1617":     "e7d34d78224cd7eb86b3842bba13e1665c97909296b3447c5ebd14a4a15b4949",
+		"MINTKERNELNODE895DIFF":    "137b1543b9ae3cc6c88a71dae07011a314a5fabcb284860d3183837719f2e0d4",
+	} {
+		require.Equal(seed+seed, hex.EncodeToString(MintSeed(light, tag)), tag)
+	}
+
+	rt, found := MintWorkHackTransaction(895)
+	require.True(found)
+	ver, err := common.UnmarshalVersionedTransaction(rt)
+	require.Nil(err)
+	r := crypto.NewKeyFromSeed(MintSeed(light, "MINTKERNELNODE895DIFF"))
+	diff := ver.Outputs[len(ver.Outputs)-1]
+	require.Equal(r.Public(), diff.Mask)
+	require.Equal("0.00000017", diff.Amount.String())
+}
+
+func TestUniversalKernelRemainder(t *testing.T) {
+	require := require.New(t)
+
+	kernel := common.NewIntegerFromString("44.93835616")
+	works := common.NewIntegerFromString("44.93835604")
+	require.Equal("0.00000012", universalKernelRemainder(kernel, works).String())
+	require.Equal(common.Zero, universalKernelRemainder(kernel, kernel))
+}
+
+func TestKernelMintOutputsEmissionTail(t *testing.T) {
+	require := require.New(t)
+
+	batch := 180 * MintYearBatches
+	base := mintBatchTotal(batch).Div(10).Mul(5)
+	require.Equal("0.00000035", base.String())
+
+	node := &Node{}
+	accepted := make([]*CNode, 10)
+	works := make(map[crypto.Hash][2]uint64)
+	for i := range accepted {
+		seed := make([]byte, 64)
+		seed[0] = byte(i + 1)
+		addr := common.NewAddressFromSeed(seed)
+		id := crypto.NewHash([]byte(fmt.Sprintf("KERNELMINTOUTPUTSTAIL%d", i)))
+		accepted[i] = &CNode{IdForNetwork: id, Signer: addr, Payee: addr}
+		works[id] = [2]uint64{100, 100}
+	}
+	works[accepted[0].IdForNetwork] = [2]uint64{1, 0}
+	mints, err := node.DistributeForWorks(accepted, works, base)
+	require.Nil(err)
+	require.Equal(0, mints[0].Work.Sign())
+
+	tx := common.NewTransactionV4(common.XINAssetId)
+	err = node.addKernelMintOutputs(tx, mints, batch)
+	require.Nil(err)
+	total, err := sumWorks(mints)
+	require.Nil(err)
+	require.Len(tx.Outputs, 9)
+	for _, o := range tx.Outputs {
+		require.Equal(1, o.Amount.Sign())
+	}
+	require.Equal("0.00000027", total.String())
+	require.Equal("0.00000008", universalKernelRemainder(base, total).String())
+
+	node.networkId, err = crypto.HashFromString(config.MainnetId)
+	require.Nil(err)
+	tx = common.NewTransactionV4(common.XINAssetId)
+	err = node.addKernelMintOutputs(tx, mints, MainnetMintZeroOutputForkBatch-1)
+	require.Nil(err)
+	require.Len(tx.Outputs, 10)
+	require.Equal(0, tx.Outputs[0].Amount.Sign())
+	tx = common.NewTransactionV4(common.XINAssetId)
+	err = node.addKernelMintOutputs(tx, mints, MainnetMintZeroOutputForkBatch)
+	require.Nil(err)
+	require.Len(tx.Outputs, 9)
+}
+
+func TestSumWorks(t *testing.T) {
+	require := require.New(t)
+
+	total, err := sumWorks(nil)
+	require.Nil(err)
+	require.Equal(0, total.Sign())
+	mints := []*CNodeWork{
+		{Work: common.NewIntegerFromString("1.5")},
+		{Work: common.Zero},
+		{Work: common.NewIntegerFromString("0.00000001")},
+		{Work: common.NewInteger(100)},
+	}
+	total, err = sumWorks(mints)
+	require.Nil(err)
+	require.Equal("101.50000001", total.String())
+}
+
+func TestNewMintTransaction(t *testing.T) {
+	require := require.New(t)
+
+	node := &Node{}
+	require.Equal(uint8(common.TxVersionBlake3Hash), node.newMintTransaction().Version)
+	node.custom = config.Default()
+	require.Equal(uint8(common.TxVersionBlake3Hash), node.newMintTransaction().Version)
+	node.custom.Node.MintTransactionVersion = common.TxVersionReferences
+	require.Equal(uint8(common.TxVersionReferences), node.newMintTransaction().Version)
+	node.custom.Node.MintTransactionVersion = common.TxVersionCommonEncoding
+	require.Equal(uint8(common.TxVersionCommonEncoding), node.newMintTransaction().Version)
+
+	node.networkId, _ = crypto.HashFromString(config.MainnetId)
+	require.Equal(uint8(common.TxVersionCommonEncoding), node.newMintTransaction().Version)
+	node.LastMint = MainnetMintTransactionV3ForkBatch
+	require.Equal(uint8(common.TxVersionBlake3Hash), node.newMintTransaction().Version)
+}
+
+func TestExpectedMintOutputCount(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	_, err = node.ExpectedMintOutputCount(0)
+	require.NotNil(err)
+	count, err := node.ExpectedMintOutputCount(895)
+	require.Nil(err)
+	require.Equal(37, count)
+	count, err = node.ExpectedMintOutputCount(1617)
+	require.Nil(err)
+	require.Equal(16, count)
+}
+
+func TestMintProposalLock(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	proposal := func(batch uint64) *common.VersionedTransaction {
+		tx := common.NewTransactionV3(common.XINAssetId)
+		tx.AddKernelNodeMintInputLegacy(batch, common.NewInteger(100))
+		return tx.AsVersioned()
+	}
+
+	// the last mint at batch 1617 and restarted 10 days later
+	appended, err := node.proposeMintSnapshot(proposal(1617))
+	require.Nil(err)
+	require.True(appended)
+	last, err := node.persistStore.ReadLastMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(1617), last)
+	for i := 0; i < 20; i++ {
+		appended, err = node.proposeMintSnapshot(proposal(1627))
+		require.Nil(err)
+		require.Equal(i == 0, appended)
+	}
+	last, err = node.persistStore.ReadLastMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(1627), last)
+
+	appended, err = node.proposeMintSnapshot(proposal(1626))
+	require.Nil(err)
+	require.False(appended)
+	last, err = node.persistStore.ReadLastMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(1627), last)
+	appended, err = node.proposeMintSnapshot(proposal(1628))
+	require.Nil(err)
+	require.True(appended)
+	last, err = node.persistStore.ReadLastMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(1628), last)
+}
+
+func TestMintPendingProposal(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	pending, err := node.pendingMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(0), pending)
+	err = node.persistStore.WriteLastMintProposal(1616)
+	require.Nil(err)
+	node.GraphTimestamp = node.mintTimestamp(1616)
+	pending, err = node.pendingMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(1616), pending)
+	node.mintTick()
+	attempts, err := node.RecentMintAttempts()
+	require.Nil(err)
+	require.Len(attempts, 0)
+
+	// the proposal of batch 1616 never finalized, and expires in batch 1617
+	node.GraphTimestamp = node.mintTimestamp(1617)
+	pending, err = node.pendingMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(0), pending)
+	node.mintTick()
+	attempts, err = node.RecentMintAttempts()
+	require.Nil(err)
+	require.Len(attempts, 0)
+
+	err = node.persistStore.WriteLastMintProposal(1617)
+	require.Nil(err)
+	pending, err = node.pendingMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(1617), pending)
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1617), true)[0].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(1617, common.NewInteger(100))
+	seed := crypto.NewHash([]byte("MINTPENDINGPROPOSAL"))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), append(seed[:], seed[:]...))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+	pending, err = node.pendingMintProposal()
+	require.Nil(err)
+	require.Equal(uint64(0), pending)
 }
 
 func TestMintWorks(t *testing.T) {
@@ -334,16 +798,2503 @@ func TestMintWorks(t *testing.T) {
 	require.True(common.NewInteger(10000).Sub(total).Cmp(common.NewIntegerFromString("0.0000001")) < 0)
 }
 
-func testBuildMintSnapshots(signers []crypto.Hash, round, timestamp uint64) []*common.SnapshotWork {
-	snapshots := make([]*common.SnapshotWork, 100)
-	for i := range snapshots {
-		hash := []byte(fmt.Sprintf("MW%d%d%d", round, timestamp, i))
-		s := common.SnapshotWork{
-			Timestamp: timestamp,
-			Hash:      crypto.NewHash(hash),
-			Signers:   signers,
+func TestMintWorksFinalized(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	day := timestamp / (uint64(time.Hour) * 24)
+	thr := node.ConsensusThreshold(timestamp, false)
+	err = node.validateWorksFinalized(node.genesisNodes, thr, day)
+	require.NotNil(err)
+	require.Contains(err.Error(), "works not final yet")
+
+	for i, id := range node.genesisNodes {
+		chain := node.getChain(id)
+		require.NotNil(chain)
+		require.NotNil(chain.State)
+		require.Equal(uint64(0), chain.State.FinalRound.Number)
+		require.Equal(uint64(1), chain.State.CacheRound.Number)
+		if i < thr-1 {
+			chain.State.FinalRound.Start = day * uint64(time.Hour) * 24
 		}
-		snapshots[i] = &s
 	}
-	return snapshots
+	err = node.validateWorksFinalized(node.genesisNodes, thr, day)
+	require.NotNil(err)
+
+	chain := node.getChain(node.genesisNodes[thr-1])
+	chain.State.FinalRound.Start = timestamp
+	err = node.validateWorksFinalized(node.genesisNodes, thr, day)
+	require.Nil(err)
+	err = node.validateWorksFinalized(node.genesisNodes, thr, day+1)
+	require.NotNil(err)
+
+	future := timestamp + uint64(time.Hour)*24
+	err = node.checkWorksFinalizedBeforeProposal(future)
+	require.NotNil(err)
+	require.Contains(err.Error(), "works not final yet")
+	node.networkId = crypto.NewHash([]byte("WORKSFINALIZEDTESTNET"))
+	err = node.checkWorksFinalizedBeforeProposal(future)
+	require.NotNil(err)
+	require.Contains(err.Error(), "works not final yet")
+	err = node.checkWorksFinalizedBeforeProposal(timestamp)
+	require.Nil(err)
+}
+
+func TestMintWorkRemovedNode(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	id := node.genesisNodes[0]
+	chain := node.getChain(id)
+	require.NotNil(chain)
+	last, err := node.persistStore.ReadLastSnapshotRound(id)
+	require.Nil(err)
+	require.Equal(uint64(0), last)
+	require.Equal(uint64(5), chain.workRoundLimit(5))
+
+	node.IdForNetwork = id
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(uint64(1616), common.NewInteger(1))
+	tx.AddScriptOutput([]*common.Address{&node.Signer}, common.NewThresholdScript(1), common.NewInteger(1), make([]byte, 64))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+	last, err = node.persistStore.ReadLastSnapshotRound(id)
+	require.Nil(err)
+	require.Equal(uint64(1), last)
+	_, err = node.persistStore.ReadLastSnapshotRound(crypto.NewHash([]byte("removed")))
+	require.NotNil(err)
+
+	now := uint64(clock.Now().UnixNano())
+	node.GraphTimestamp = now + 1
+	node.allNodesSortedWithState = append(node.allNodesSortedWithState, &CNode{
+		IdForNetwork: id,
+		Timestamp:    now,
+		State:        common.NodeStateRemoved,
+	})
+	node.nodeStateSequences = node.buildNodeStateSequences(node.allNodesSortedWithState, false)
+	require.Equal(uint64(5), chain.workRoundLimit(5))
+	node.resetNodesListCache()
+	require.Equal(uint64(1), chain.workRoundLimit(5))
+	require.Equal(uint64(0), chain.workRoundLimit(0))
+
+	// the removal round is read once, and kept after the node list changes
+	chain.workLimit.round = 2
+	node.resetNodesListCache()
+	require.Equal(uint64(2), chain.workRoundLimit(5))
+}
+
+func TestForkFilteredRounds(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	chain := node.getChain(node.genesisNodes[0])
+	rounds, err := chain.ForkFilteredRounds()
+	require.Nil(err)
+	require.Equal([]uint64{0}, rounds)
+
+	chain = node.getChain(node.IdForNetwork)
+	rounds, err = chain.ForkFilteredRounds()
+	require.Nil(err)
+	require.Len(rounds, 0)
+
+	node.networkId = crypto.NewHash([]byte("FORKFILTEREDROUNDS"))
+	chain = node.getChain(node.genesisNodes[0])
+	rounds, err = chain.ForkFilteredRounds()
+	require.Nil(err)
+	require.Nil(rounds)
+}
+
+func TestRecomputeRoundWork(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	chain := node.getChain(node.genesisNodes[0])
+	works, err := chain.RecomputeRoundWork(0)
+	require.Nil(err)
+	require.Nil(works)
+	works, err = chain.RecomputeRoundWork(1)
+	require.Nil(err)
+	require.Nil(works)
+
+	node.networkId = crypto.NewHash([]byte("RECOMPUTEROUNDWORK"))
+	works, err = chain.RecomputeRoundWork(0)
+	require.Nil(err)
+	stored, err := node.persistStore.ReadSnapshotWorksForNodeRound(chain.ChainId, 0)
+	require.Nil(err)
+	require.Len(works, len(stored))
+	require.Greater(len(works), 0)
+	for i, w := range works {
+		require.Equal(stored[i].Hash, w.Hash)
+		require.Equal(stored[i].Timestamp, w.Timestamp)
+		require.Equal(stored[i].Signers, w.Signers)
+	}
+
+	// the snapshot works of round 0 are removed once round 1 is aggregated
+	err = node.persistStore.WriteRoundWork(chain.ChainId, 0, stored)
+	require.Nil(err)
+	err = node.persistStore.WriteRoundWork(chain.ChainId, 1, nil)
+	require.Nil(err)
+	removed, err := node.persistStore.ReadSnapshotWorksForNodeRound(chain.ChainId, 0)
+	require.Nil(err)
+	require.Len(removed, 0)
+	works, err = chain.RecomputeRoundWork(0)
+	require.Nil(err)
+	require.Len(works, len(stored))
+	for i, w := range works {
+		require.Equal(stored[i].Hash, w.Hash)
+		require.Equal(stored[i].Timestamp, w.Timestamp)
+	}
+}
+
+func TestNodeSnapshotsForBatch(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	id := node.genesisNodes[0]
+	stored, err := node.persistStore.ReadSnapshotsForNodeRound(id, 0)
+	require.Nil(err)
+	require.Greater(len(stored), 0)
+	works, err := node.NodeSnapshotsForBatch(id, 0)
+	require.Nil(err)
+	require.Len(works, len(stored))
+	for i, w := range works {
+		require.Equal(stored[i].Hash, w.Hash)
+		require.Equal(stored[i].Timestamp, w.Timestamp)
+	}
+
+	works, err = node.NodeSnapshotsForBatch(id, 1)
+	require.Nil(err)
+	require.Len(works, 0)
+	_, err = node.NodeSnapshotsForBatch(crypto.NewHash([]byte("NODESNAPSHOTSFORBATCH")), 0)
+	require.NotNil(err)
+}
+
+func TestWriteRoundWorkForkFiltered(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	id := node.genesisNodes[0]
+	chain := node.getChain(id)
+	stored, err := node.persistStore.ReadSnapshotWorksForNodeRound(id, 0)
+	require.Nil(err)
+	require.Greater(len(stored), 0)
+	require.Nil(chain.filterRoundWork(stored))
+
+	for _, round := range []uint64{0, 1, 1} {
+		err = node.persistStore.WriteRoundWork(id, round, nil)
+		require.Nil(err)
+		offset, err := node.persistStore.ReadWorkOffset(id)
+		require.Nil(err)
+		require.Equal(round, offset)
+	}
+	day := uint32(stored[0].Timestamp / uint64(time.Hour*24))
+	works, err := node.persistStore.ListNodeWorks(node.genesisNodes, day)
+	require.Nil(err)
+	for _, w := range works {
+		require.Equal([2]uint64{0, 0}, w)
+	}
+
+	timestamp := uint64(clock.Now().UnixNano())
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 2, timestamp)
+	err = node.persistStore.WriteRoundWork(id, 2, snapshots)
+	require.Nil(err)
+	offset, err := node.persistStore.ReadWorkOffset(id)
+	require.Nil(err)
+	require.Equal(uint64(2), offset)
+	works, err = node.persistStore.ListNodeWorks(node.genesisNodes, uint32(timestamp/uint64(time.Hour*24)))
+	require.Nil(err)
+	require.Equal(uint64(len(snapshots)), works[id][0])
+}
+
+func TestMintWorkResume(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	signers := append(node.genesisNodes, node.IdForNetwork)
+	timestamp := uint64(clock.Now().UnixNano())
+	day := uint32(timestamp / uint64(time.Hour*24))
+	snapshots := testBuildMintSnapshots(signers[1:], 0, timestamp)
+
+	// crash after the partial round work committed
+	err = node.persistStore.WriteRoundWork(node.IdForNetwork, 0, snapshots[:60])
+	require.Nil(err)
+	round, err := node.persistStore.ReadWorkOffset(node.IdForNetwork)
+	require.Nil(err)
+	require.Equal(uint64(0), round)
+
+	// resume from the offset round, and again as if crashed before advance
+	for i := 0; i < 2; i++ {
+		err = node.persistStore.WriteRoundWork(node.IdForNetwork, round, snapshots)
+		require.Nil(err)
+		works, err := node.persistStore.ListNodeWorks(signers, day)
+		require.Nil(err)
+		require.Equal(uint64(100), works[node.IdForNetwork][0])
+		require.Equal(uint64(100), works[signers[1]][1])
+	}
+
+	snapshots = testBuildMintSnapshots(signers[1:], 1, timestamp)
+	err = node.persistStore.WriteRoundWork(node.IdForNetwork, round+1, snapshots)
+	require.Nil(err)
+	works, err := node.persistStore.ListNodeWorks(signers, day)
+	require.Nil(err)
+	require.Equal(uint64(200), works[node.IdForNetwork][0])
+	round, err = node.persistStore.ReadWorkOffset(node.IdForNetwork)
+	require.Nil(err)
+	require.Equal(uint64(1), round)
+}
+
+func TestMintEqualSplit(t *testing.T) {
+	require := require.New(t)
+
+	mints := make([]*CNodeWork, 7)
+	for i := range mints {
+		id := crypto.NewHash([]byte(fmt.Sprintf("MINTEQUALSPLIT%d", i)))
+		mints[i] = &CNodeWork{CNode: CNode{IdForNetwork: id}}
+	}
+	base := common.NewInteger(100)
+	mints = distributeKernelMintEqually(mints, base)
+	require.Len(mints, 7)
+
+	total := common.NewInteger(0)
+	first := mints[0]
+	for _, m := range mints {
+		total = total.Add(m.Work)
+		if m.IdForNetwork.String() < first.IdForNetwork.String() {
+			first = m
+		}
+	}
+	require.Equal(base, total)
+	for _, m := range mints {
+		if m == first {
+			require.Equal("14.28571432", m.Work.String())
+		} else {
+			require.Equal("14.28571428", m.Work.String())
+		}
+	}
+}
+
+func TestMintWorkHacks(t *testing.T) {
+	require := require.New(t)
+
+	batches := MintWorkHackBatches()
+	require.Equal([]uint64{895}, batches)
+	for _, b := range batches {
+		rt, found := MintWorkHackTransaction(b)
+		require.True(found)
+		ver, err := common.UnmarshalVersionedTransaction(rt)
+		require.Nil(err)
+		require.Equal(b, ver.Inputs[0].Mint.Batch)
+		require.Equal(TransactionMintWorkHacks[int(b)], hex.EncodeToString(rt))
+	}
+
+	rt, found := MintWorkHackTransaction(896)
+	require.False(found)
+	require.Nil(rt)
+}
+
+func TestFormatMintWorks(t *testing.T) {
+	require := require.New(t)
+
+	works := make(map[crypto.Hash][2]uint64)
+	ids := make([]string, 5)
+	for i := range ids {
+		id := crypto.NewHash([]byte(fmt.Sprintf("FORMATMINTWORKS%d", i)))
+		works[id] = [2]uint64{uint64(i), uint64(i * 10)}
+		ids[i] = id.String()
+	}
+	out := formatMintWorks(works)
+	for i := 0; i < 10; i++ {
+		require.Equal(out, formatMintWorks(works))
+	}
+
+	tuples := strings.Split(out, " ")
+	require.Len(tuples, 5)
+	for i := 1; i < len(tuples); i++ {
+		require.True(tuples[i-1] < tuples[i])
+	}
+	require.Contains(out, fmt.Sprintf("(%s,3,30)", ids[3]))
+	require.Equal("", formatMintWorks(nil))
+}
+
+func TestMintWorkDayBoundary(t *testing.T) {
+	require := require.New(t)
+
+	_, err := previousWorkDay(0)
+	require.NotNil(err)
+	prev, err := previousWorkDay(1)
+	require.Nil(err)
+	require.Equal(uint32(0), prev)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	accepted := node.NodesListWithoutState(node.Epoch+1, true)
+	mints, err := node.distributeKernelMintByWorks(accepted, common.NewInteger(100), node.Epoch)
+	require.Nil(err)
+	require.Len(mints, len(accepted))
+
+	timestamp := node.Epoch + uint64(time.Hour*24)
+	_, err = node.distributeKernelMintByWorks(accepted, common.NewInteger(100), timestamp)
+	require.NotNil(err)
+	require.Contains(err.Error(), "not ready yet")
+}
+
+func TestNodeWork(t *testing.T) {
+	require := require.New(t)
+
+	w := NewNodeWork([2]uint64{100, 30})
+	require.Equal(uint64(100), w.Produced)
+	require.Equal(uint64(30), w.Signed)
+	require.Equal([2]uint64{100, 30}, w.tuple())
+	require.False(w.IsZero())
+	require.Equal("150.00000000", w.Score().String())
+
+	w = NewNodeWork([2]uint64{0, 0})
+	require.True(w.IsZero())
+	require.Equal(0, w.Score().Sign())
+	require.Equal("7.00000000", NodeWork{Signed: 7}.Score().String())
+	require.Equal("8.40000000", NodeWork{Produced: 7}.Score().String())
+}
+
+func TestWorkScoreBreakdown(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 0, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 0, snapshots)
+	require.Nil(err)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[1], 0, snapshots)
+	require.Nil(err)
+
+	day := timestamp / (uint64(time.Hour) * 24)
+	batch := day - node.Epoch/(uint64(time.Hour)*24) + 1
+	produced, signed, err := node.WorkScoreBreakdown(node.genesisNodes[0], batch)
+	require.Nil(err)
+	require.Equal("120.00000000", produced.String())
+	require.Equal("100.00000000", signed.String())
+	produced, signed, err = node.WorkScoreBreakdown(node.genesisNodes[2], batch)
+	require.Nil(err)
+	require.Equal("0.00000000", produced.String())
+	require.Equal("200.00000000", signed.String())
+
+	_, _, err = node.WorkScoreBreakdown(crypto.NewHash([]byte("WORKSCOREBREAKDOWN")), batch)
+	require.NotNil(err)
+	_, _, err = node.WorkScoreBreakdown(node.genesisNodes[0], 0)
+	require.NotNil(err)
+}
+
+func TestDistributeForWorks(t *testing.T) {
+	require := require.New(t)
+
+	node := &Node{}
+	accepted := make([]*CNode, 10)
+	works := make(map[crypto.Hash][2]uint64)
+	for i := range accepted {
+		id := crypto.NewHash([]byte(fmt.Sprintf("DISTRIBUTEFORWORKS%d", i)))
+		accepted[i] = &CNode{IdForNetwork: id}
+		works[id] = [2]uint64{100, 100}
+	}
+	base := common.NewInteger(1000)
+	mints, err := node.DistributeForWorks(accepted, works, base)
+	require.Nil(err)
+	require.Len(mints, 10)
+	for _, m := range mints {
+		require.Equal("100.00000000", m.Work.String())
+	}
+
+	works[accepted[0].IdForNetwork] = [2]uint64{10000, 10000}
+	works[accepted[1].IdForNetwork] = [2]uint64{1, 0}
+	mints, err = node.DistributeForWorks(accepted, works, base)
+	require.Nil(err)
+	total := common.Zero
+	for _, m := range mints {
+		total = total.Add(m.Work)
+	}
+	require.True(total.Cmp(base) <= 0)
+	require.Equal(1, mints[0].Work.Cmp(mints[2].Work))
+	require.Equal(1, mints[2].Work.Cmp(mints[1].Work))
+	require.Equal(1, mints[2].Work.Div(6).Cmp(mints[1].Work))
+
+	for i := 0; i < 4; i++ {
+		delete(works, accepted[i].IdForNetwork)
+	}
+	_, err = node.DistributeForWorks(accepted, works, base)
+	require.NotNil(err)
+	require.Contains(err.Error(), "threshold 7 valid 6")
+}
+
+func TestMintClampClassification(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	signers := node.genesisNodes[:len(node.genesisNodes)-2]
+	snapshots := testBuildMintSnapshots(signers, 0, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 0, snapshots)
+	require.Nil(err)
+
+	day := timestamp / (uint64(time.Hour) * 24)
+	batch := day - node.Epoch/(uint64(time.Hour)*24) + 1
+	high, mid, low, err := node.MintClampClassification(batch)
+	require.Nil(err)
+	require.Len(high, 0)
+	require.Len(mid, len(signers))
+	require.Len(low, 2)
+
+	_, _, _, err = node.MintClampClassification(0)
+	require.NotNil(err)
+
+	thr := node.MintConsensusThreshold(node.Epoch + batch*uint64(time.Hour*24))
+	require.Equal(node.ConsensusThreshold(node.Epoch+batch*uint64(time.Hour*24), false), thr)
+	require.Equal(len(node.genesisNodes)*2/3+1, thr)
+
+	avg := common.NewInteger(100)
+	require.Equal(1, clampKernelMintWork(common.NewInteger(700), avg))
+	require.Equal(0, clampKernelMintWork(common.NewInteger(699), avg))
+	require.Equal(0, clampKernelMintWork(common.NewInteger(15), avg))
+	require.Equal(-1, clampKernelMintWork(common.NewIntegerFromString("14.28571428"), avg))
+	require.Equal(-1, clampKernelMintWork(common.Zero, avg))
+}
+
+func TestMintConsensusThresholdPledging(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	start := node.mintTimestamp(1616) / uint64(time.Hour*24) * uint64(time.Hour*24)
+	for i := 0; i < 2; i++ {
+		node.allNodesSortedWithState = append(node.allNodesSortedWithState, &CNode{
+			IdForNetwork: crypto.NewHash([]byte(fmt.Sprintf("MINTTHRESHOLDPLEDGING%d", i))),
+			Timestamp:    start - uint64(4*time.Hour),
+			State:        common.NodeStatePledging,
+		})
+	}
+	node.nodeStateSequences = node.buildNodeStateSequences(node.allNodesSortedWithState, false)
+
+	before, after := start+uint64(7*time.Hour), start+uint64(9*time.Hour)
+	require.Len(node.NodesListWithoutState(after, true), len(node.genesisNodes))
+	require.Equal(len(node.genesisNodes)*2/3+1, node.MintConsensusThreshold(before))
+	require.Equal((len(node.genesisNodes)+2)*2/3+1, node.MintConsensusThreshold(after))
+
+	// mainnet pins the threshold since the fork batch
+	fork := node.mintTimestamp(MainnetMintThresholdForkBatch) / uint64(time.Hour*24) * uint64(time.Hour*24)
+	for _, n := range node.allNodesSortedWithState[len(node.allNodesSortedWithState)-2:] {
+		n.Timestamp = fork - uint64(4*time.Hour)
+	}
+	node.nodeStateSequences = node.buildNodeStateSequences(node.allNodesSortedWithState, false)
+	require.Equal(len(node.genesisNodes)*2/3+1, node.MintConsensusThreshold(fork+uint64(7*time.Hour)))
+	require.Equal(len(node.genesisNodes)*2/3+1, node.MintConsensusThreshold(fork+uint64(9*time.Hour)))
+	require.Equal((len(node.genesisNodes)+2)*2/3+1, node.ConsensusThreshold(fork+uint64(9*time.Hour), false))
+	for _, n := range node.allNodesSortedWithState[len(node.allNodesSortedWithState)-2:] {
+		n.Timestamp = start - uint64(4*time.Hour)
+	}
+	node.nodeStateSequences = node.buildNodeStateSequences(node.allNodesSortedWithState, false)
+
+	node.networkId = crypto.NewHash([]byte("MINTTHRESHOLDPLEDGING"))
+	require.Equal(len(node.genesisNodes)*2/3+1, node.MintConsensusThreshold(before))
+	require.Equal(len(node.genesisNodes)*2/3+1, node.MintConsensusThreshold(after))
+	require.Equal((len(node.genesisNodes)+2)*2/3+1, node.ConsensusThreshold(after, false))
+}
+
+func TestMintAuditTable(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	signers := node.genesisNodes[:len(node.genesisNodes)-2]
+	snapshots := testBuildMintSnapshots(signers, 0, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 0, snapshots)
+	require.Nil(err)
+
+	day := timestamp / (uint64(time.Hour) * 24)
+	batch := day - node.Epoch/(uint64(time.Hour)*24) + 1
+	rows, err := node.MintAuditTable(batch)
+	require.Nil(err)
+	require.Len(rows, len(node.genesisNodes))
+
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+	total, idle := common.NewInteger(0), 0
+	for _, r := range rows {
+		require.True(r.ClampedWork.Sign() > 0)
+		require.Equal(r.Ration.Product(base), r.Amount)
+		total = total.Add(r.Amount)
+		if r.RawWork.IsZero() {
+			idle += 1
+		}
+	}
+	require.Equal(2, idle)
+	require.True(total.Cmp(base) <= 0)
+	require.True(total.Cmp(base.Sub(common.NewIntegerFromString("0.00000100"))) > 0)
+
+	_, err = node.MintAuditTable(0)
+	require.NotNil(err)
+}
+
+func TestRewardVariance(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	batch := testWriteMintWorks(require, node)
+	epoch := node.Epoch / (uint64(time.Hour) * 24)
+	today := uint32(batch + epoch)
+	id := node.genesisNodes[len(node.genesisNodes)-1]
+	mean, stddev, err := node.RewardVariance(id, today-1, today)
+	require.Nil(err)
+	require.Equal(0, stddev.Sign())
+	rows, err := node.MintAuditTable(batch)
+	require.Nil(err)
+	require.Equal(rows[0].Amount, mean)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	signers := node.genesisNodes[:len(node.genesisNodes)-1]
+	snapshots := testBuildMintSnapshots(signers, 2, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 2, snapshots)
+	require.Nil(err)
+
+	var amounts []common.Integer
+	for _, b := range []uint64{batch, batch + 1} {
+		rows, err := node.MintAuditTable(b)
+		require.Nil(err)
+		for _, r := range rows {
+			if r.NodeId == id {
+				amounts = append(amounts, r.Amount)
+			}
+		}
+	}
+	require.Len(amounts, 2)
+	require.Equal(1, amounts[0].Cmp(amounts[1]))
+	mean, stddev, err = node.RewardVariance(id, today-1, today)
+	require.Nil(err)
+	require.Equal(amounts[0].Add(amounts[1]).Div(2), mean)
+	half := amounts[0].Sub(amounts[1]).Div(2)
+	require.True(stddev.Cmp(half) >= 0)
+	require.True(stddev.Cmp(half.Add(common.NewIntegerFromString("0.00000001"))) <= 0)
+
+	_, _, err = node.RewardVariance(id, today, today-1)
+	require.NotNil(err)
+	_, _, err = node.RewardVariance(id, uint32(epoch)-1, today)
+	require.NotNil(err)
+
+	one, two := common.NewInteger(1), common.NewInteger(2)
+	require.Equal(one, integerDistance(one, common.Zero))
+	require.Equal(one, integerDistance(common.Zero, one))
+	require.Equal(one, integerDistance(one, two))
+	require.Equal(0, integerDistance(two, two).Sign())
+}
+
+func TestNetworkAverageWork(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	batch := testWriteMintWorks(require, node)
+	epoch := node.Epoch / (uint64(time.Hour) * 24)
+	today := uint32(batch + epoch)
+	_, _, first, err := node.averageBatchMintWorks(batch)
+	require.Nil(err)
+	avg, err := node.NetworkAverageWork(today-1, today-1)
+	require.Nil(err)
+	require.Equal(first, avg)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 2, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 2, snapshots)
+	require.Nil(err)
+	_, _, second, err := node.averageBatchMintWorks(batch + 1)
+	require.Nil(err)
+	require.Equal(1, second.Cmp(first))
+	avg, err = node.NetworkAverageWork(today-1, today)
+	require.Nil(err)
+	require.Equal(first.Add(second).Div(2), avg)
+
+	_, err = node.NetworkAverageWork(today, today-1)
+	require.NotNil(err)
+	_, err = node.NetworkAverageWork(today+1, today+1)
+	require.NotNil(err)
+}
+
+func TestRewardElasticity(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	signers := node.genesisNodes[:len(node.genesisNodes)-2]
+	snapshots := testBuildMintSnapshots(signers, 0, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 0, snapshots)
+	require.Nil(err)
+
+	day := timestamp / (uint64(time.Hour) * 24)
+	batch := day - node.Epoch/(uint64(time.Hour)*24) + 1
+	below, above, err := node.RewardElasticity(batch)
+	require.Nil(err)
+	require.Equal(1, below.Cmp(above))
+	work := common.NewInteger(100)
+	require.Equal(below.Product(work), above.Product(work.Mul(6)))
+
+	accepted := node.NodesListWithoutState(node.Epoch+batch*uint64(time.Hour*24), true)
+	cids := make([]crypto.Hash, len(accepted))
+	for i, n := range accepted {
+		cids[i] = n.IdForNetwork
+	}
+	works, err := node.persistStore.ListNodeWorks(cids, uint32(day))
+	require.Nil(err)
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+	mints, err := node.DistributeForWorks(accepted, works, base)
+	require.Nil(err)
+	for _, m := range mints {
+		if w := works[m.IdForNetwork]; w == [2]uint64{0, 100} {
+			require.Equal(below.Product(work), m.Work)
+		}
+	}
+
+	_, _, err = node.RewardElasticity(0)
+	require.NotNil(err)
+}
+
+func TestPreviewNodeSetChange(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 0, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 0, snapshots)
+	require.Nil(err)
+
+	day := timestamp / (uint64(time.Hour) * 24)
+	batch := day - node.Epoch/(uint64(time.Hour)*24) + 1
+	accepted := node.NodesListWithoutState(node.Epoch+batch*uint64(time.Hour*24), true)
+	add := []*CNode{{IdForNetwork: crypto.NewHash([]byte("PREVIEWNODESETCHANGE"))}}
+	remove := []*CNode{accepted[len(accepted)-1]}
+	before, after, err := node.PreviewNodeSetChange(batch, add, remove)
+	require.Nil(err)
+	require.Len(before, len(accepted))
+	require.Len(after, len(accepted))
+	require.NotContains(after, remove[0].IdForNetwork)
+	require.Contains(after, add[0].IdForNetwork)
+	require.Equal(1, after[add[0].IdForNetwork].Cmp(common.Zero))
+
+	before, after, err = node.PreviewNodeSetChange(batch, add, nil)
+	require.Nil(err)
+	require.Len(after, len(accepted)+1)
+	for id, r := range before {
+		require.Equal(-1, after[id].Cmp(r))
+	}
+
+	_, _, err = node.PreviewNodeSetChange(0, add, nil)
+	require.NotNil(err)
+}
+
+func TestNextMintReceipt(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	id := node.genesisNodes[1]
+	node.GraphTimestamp = uint64(clock.Now().UnixNano())
+	_, _, _, err = node.NextMintReceipt(id)
+	require.NotNil(err)
+
+	batch := uint64(node.mintBatch(node.GraphTimestamp))
+	timestamp := node.Epoch + (batch-1)*uint64(time.Hour*24)
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 0, timestamp)
+	for _, cid := range node.genesisNodes {
+		err = node.persistStore.WriteRoundWork(cid, 0, snapshots)
+		require.Nil(err)
+	}
+
+	next, amount, sig, err := node.NextMintReceipt(id)
+	require.Nil(err)
+	require.Equal(batch, next)
+	require.True(amount.Sign() > 0)
+	msg := MintReceiptMessage(id, next, amount)
+	require.True(node.Signer.PublicSpendKey.Verify(msg, sig))
+	msg = MintReceiptMessage(id, next, amount.Add(common.NewInteger(1)))
+	require.False(node.Signer.PublicSpendKey.Verify(msg, sig))
+	require.True(bytes.HasPrefix(msg, []byte("MINTRECEIPT")))
+
+	signer := &testMintSigner{key: crypto.NewKeyFromSeed(bytes.Repeat([]byte{2}, 64))}
+	node.MintSigner = signer
+	_, _, sig, err = node.NextMintReceipt(id)
+	require.Nil(err)
+	require.Equal(1, signer.calls)
+	pub := signer.key.Public()
+	require.True(pub.Verify(MintReceiptMessage(id, next, amount), sig))
+	signer.err = fmt.Errorf("hsm unavailable")
+	_, _, _, err = node.NextMintReceipt(id)
+	require.NotNil(err)
+	require.Contains(err.Error(), "hsm unavailable")
+	node.MintSigner = nil
+
+	_, _, _, err = node.NextMintReceipt(node.IdForNetwork)
+	require.NotNil(err)
+	node.Signer.PrivateSpendKey = crypto.Key{}
+	_, _, _, err = node.NextMintReceipt(id)
+	require.NotNil(err)
+	require.Contains(err.Error(), "no mint signer")
+}
+
+func TestProjectNodeEarnings(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	id := node.genesisNodes[1]
+	node.GraphTimestamp = uint64(clock.Now().UnixNano())
+	batch := uint64(node.mintBatch(node.GraphTimestamp))
+	timestamp := node.Epoch + (batch-1)*uint64(time.Hour*24)
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 0, timestamp)
+	for _, cid := range node.genesisNodes {
+		err = node.persistStore.WriteRoundWork(cid, 0, snapshots)
+		require.Nil(err)
+	}
+
+	_, err = node.ProjectNodeEarnings(id, 0)
+	require.NotNil(err)
+	_, err = node.ProjectNodeEarnings(node.IdForNetwork, 1)
+	require.NotNil(err)
+
+	base := mintBatchTotal(int(batch)).Div(10).Mul(5)
+	daily, err := node.ProjectNodeEarnings(id, 1)
+	require.Nil(err)
+	require.Equal(base.Div(len(node.genesisNodes)), daily)
+	monthly, err := node.ProjectNodeEarnings(id, 30)
+	require.Nil(err)
+	require.True(monthly.Cmp(daily.Mul(30)) <= 0)
+	require.True(monthly.Cmp(daily.Mul(27)) > 0)
+}
+
+func TestValidatorTable(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 0, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 0, snapshots)
+	require.Nil(err)
+
+	rows, err := node.ValidatorTable(timestamp)
+	require.Nil(err)
+	require.Len(rows, len(node.genesisNodes))
+	for _, r := range rows {
+		require.True(node.genesisNodesMap[r.IdForNetwork])
+		require.Equal(pledgeAmount(0), r.Pledge)
+		require.True(r.Payee.PublicSpendKey.HasValue())
+		if r.IdForNetwork == node.genesisNodes[0] {
+			require.Equal(NodeWork{Produced: 100}, r.Works)
+		} else {
+			require.Equal(NodeWork{Signed: 100}, r.Works)
+		}
+	}
+}
+
+func TestNodeRoundSpaceBatches(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	id := node.genesisNodes[0]
+	duration := uint64(config.CheckpointDuration)
+	for _, space := range []*common.RoundSpace{
+		{NodeId: id, Batch: 3, Round: 1, Duration: duration},
+		{NodeId: id, Batch: 3, Round: 2, Duration: duration * 2},
+		{NodeId: id, Batch: 5, Round: 7, Duration: duration},
+		{NodeId: id, Batch: 6, Round: 8, Duration: 0},
+	} {
+		err = node.persistStore.WriteRoundSpaceAndState(space)
+		require.Nil(err)
+	}
+
+	batches, err := node.NodeRoundSpaceBatches(id)
+	require.Nil(err)
+	require.Equal([]uint64{3, 5}, batches)
+	spaces, err := node.persistStore.ReadNodeRoundSpacesForBatch(id, 3)
+	require.Nil(err)
+	require.Len(spaces, 2)
+
+	batches, err = node.NodeRoundSpaceBatches(node.genesisNodes[1])
+	require.Nil(err)
+	require.Len(batches, 0)
+}
+
+func TestOrphanWorks(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := uint64(clock.Now().UnixNano())
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 0, timestamp)
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[0], 0, snapshots)
+	require.Nil(err)
+
+	day := timestamp / (uint64(time.Hour) * 24)
+	batch := day - node.Epoch/(uint64(time.Hour)*24) + 1
+	orphans, err := node.OrphanWorks(batch)
+	require.Nil(err)
+	require.Len(orphans, 0)
+
+	accepted := node.NodesListWithoutState(timestamp+1, true)
+	require.Len(accepted, len(node.genesisNodes))
+	removed := accepted[5].IdForNetwork
+	accepted = append(accepted[:5:5], accepted[6:]...)
+	orphans, err = node.listOrphanWorks(accepted, timestamp+1, day)
+	require.Nil(err)
+	require.Equal([]crypto.Hash{removed}, orphans)
+
+	_, err = node.OrphanWorks(0)
+	require.NotNil(err)
+}
+
+func TestMintSnapshotProducer(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := node.Epoch + 1617*uint64(time.Hour*24) + 8*uint64(time.Hour)
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1617, common.NewInteger(100))
+	snap := &common.Snapshot{
+		Version:   common.SnapshotVersionCommonEncoding,
+		NodeId:    crypto.NewHash([]byte("MINTSNAPSHOTPRODUCER")),
+		Timestamp: timestamp,
+	}
+	err = node.validateMintSnapshot(snap, tx.AsVersioned())
+	require.NotNil(err)
+	require.NotContains(err.Error(), "mint snapshot from invalid node")
+
+	snap.Timestamp = node.mintTimestamp(MainnetMintProducerForkBatch)
+	err = node.validateMintSnapshot(snap, tx.AsVersioned())
+	require.NotNil(err)
+	require.Contains(err.Error(), "mint snapshot from invalid node")
+
+	snap.NodeId = node.genesisNodes[1]
+	err = node.validateMintSnapshot(snap, tx.AsVersioned())
+	require.NotNil(err)
+	require.NotContains(err.Error(), "mint snapshot from invalid node")
+}
+
+func TestLegacyMintMainnet(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	require.True(node.isMainnet())
+
+	timestamp := node.Epoch + 1000*uint64(time.Hour*24) + 8*uint64(time.Hour)
+	tx := common.NewTransactionV2(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(1000, common.NewInteger(100))
+	snap := &common.Snapshot{
+		Version:   common.SnapshotVersionCommonEncoding,
+		NodeId:    node.genesisNodes[1],
+		Timestamp: timestamp,
+	}
+	err = node.validateMintSnapshot(snap, tx.AsVersioned())
+	require.NotNil(err)
+	require.NotContains(err.Error(), "legacy mint disabled")
+
+	node.networkId = crypto.NewHash([]byte("LEGACYMINTTESTNET"))
+	require.False(node.isMainnet())
+	err = node.validateMintSnapshot(snap, tx.AsVersioned())
+	require.NotNil(err)
+	require.Contains(err.Error(), "legacy mint disabled")
+}
+
+type testMintSigner struct {
+	key   crypto.Key
+	calls int
+	err   error
+}
+
+func (s *testMintSigner) SignInput(reader common.UTXOKeysReader, tx *common.VersionedTransaction, index int, accounts []*common.Address) error {
+	s.calls += 1
+	if s.err != nil {
+		return s.err
+	}
+	return tx.SignRaw(s.key)
+}
+
+func (s *testMintSigner) SignMessage(account *common.Address, msg []byte) (crypto.Signature, error) {
+	s.calls += 1
+	if s.err != nil {
+		return crypto.Signature{}, s.err
+	}
+	return s.key.Sign(msg), nil
+}
+
+func TestMintSigner(t *testing.T) {
+	require := require.New(t)
+
+	seed := make([]byte, 64)
+	copy(seed, []byte("MINTSIGNER"))
+	account := common.NewAddressFromSeed(seed)
+	node := &Node{Signer: account}
+
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1617, common.NewInteger(100))
+	local := tx.AsVersioned()
+	err := node.signMintTransaction(local)
+	require.Nil(err)
+	require.Len(local.SignaturesMap, 1)
+
+	signer := &testMintSigner{key: account.PrivateSpendKey}
+	node.MintSigner = signer
+	remote := tx.AsVersioned()
+	remote.SignaturesMap = nil
+	err = node.signMintTransaction(remote)
+	require.Nil(err)
+	require.Equal(1, signer.calls)
+	require.Equal(local.SignaturesMap, remote.SignaturesMap)
+
+	signer.err = fmt.Errorf("hsm unavailable")
+	err = node.signMintTransaction(tx.AsVersioned())
+	require.NotNil(err)
+	require.Equal(2, signer.calls)
+	require.Contains(err.Error(), "hsm unavailable")
+}
+
+func TestMintPreCommit(t *testing.T) {
+	require := require.New(t)
+
+	account := common.NewAddressFromSeed(make([]byte, 64))
+	node := &Node{Signer: account}
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1617, common.NewInteger(100))
+	signed := tx.AsVersioned()
+	err := node.signMintTransaction(signed)
+	require.Nil(err)
+
+	err = node.preCommitMint(signed)
+	require.Nil(err)
+
+	var received *common.VersionedTransaction
+	node.MintPreCommit = func(tx *common.VersionedTransaction) error {
+		received = tx
+		return nil
+	}
+	err = node.preCommitMint(signed)
+	require.Nil(err)
+	require.Equal(signed.PayloadHash(), received.PayloadHash())
+	require.Len(received.SignaturesMap, 1)
+
+	node.MintPreCommit = func(tx *common.VersionedTransaction) error {
+		return fmt.Errorf("compliance rejected %d", tx.Inputs[0].Mint.Batch)
+	}
+	err = node.preCommitMint(signed)
+	require.NotNil(err)
+	require.Contains(err.Error(), "mint vetoed by pre commit")
+	require.Contains(err.Error(), "compliance rejected 1617")
+}
+
+type testCustodianErrorStore struct {
+	storage.Store
+	reads    int32
+	attempts []*common.MintAttempt
+}
+
+func (s *testCustodianErrorStore) ReadCustodian(ts uint64) (*common.CustodianUpdateRequest, error) {
+	atomic.AddInt32(&s.reads, 1)
+	return nil, fmt.Errorf("custodian read error %d", ts)
+}
+
+func (s *testCustodianErrorStore) WriteMintAttempt(attempt *common.MintAttempt, limit int) error {
+	s.attempts = append(s.attempts, attempt)
+	return nil
+}
+
+func TestMintLoopCustodianError(t *testing.T) {
+	require := require.New(t)
+
+	custom := &config.Custom{}
+	custom.Node.KernelOprationPeriod = 1
+	epoch := uint64(time.Date(2019, 2, 28, 0, 0, 0, 0, time.UTC).UnixNano())
+	store := &testCustodianErrorStore{}
+	node := &Node{
+		Signer:         common.NewAddressFromSeed(bytes.Repeat([]byte{1}, 64)),
+		Epoch:          epoch,
+		GraphTimestamp: epoch + 100*uint64(time.Hour*24) + 8*uint64(time.Hour),
+		persistStore:   store,
+		custom:         custom,
+		done:           make(chan struct{}),
+		mlc:            make(chan struct{}),
+	}
+
+	go node.MintLoop()
+	for i := 0; i < 50 && atomic.LoadInt32(&store.reads) < 2; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+	close(node.done)
+	<-node.mlc
+
+	require.GreaterOrEqual(atomic.LoadInt32(&store.reads), int32(2))
+	require.GreaterOrEqual(len(store.attempts), 2)
+	require.Equal(uint64(100), store.attempts[0].Batch)
+	require.Equal("failed", store.attempts[0].Outcome)
+	require.Contains(store.attempts[0].Error, "custodian read error")
+}
+
+func TestIsDesignatedMinter(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	require.False(node.IsDesignatedMinter(1600))
+
+	rounds := 4
+	counts := make(map[crypto.Hash]int)
+	for batch := uint64(1600); batch < uint64(1600+rounds*len(node.genesisNodes)); batch++ {
+		var designated int
+		for _, id := range node.genesisNodes {
+			node.IdForNetwork = id
+			if node.IsDesignatedMinter(batch) {
+				designated += 1
+				counts[id] += 1
+			}
+		}
+		require.Equal(1, designated, batch)
+	}
+	require.Len(counts, len(node.genesisNodes))
+	for _, c := range counts {
+		require.Equal(rounds, c)
+	}
+}
+
+func TestMintLoopWithoutSigner(t *testing.T) {
+	require := require.New(t)
+
+	custom := &config.Custom{}
+	custom.Node.KernelOprationPeriod = 1
+	epoch := uint64(time.Date(2019, 2, 28, 0, 0, 0, 0, time.UTC).UnixNano())
+	store := &testCustodianErrorStore{}
+	node := &Node{
+		Epoch:          epoch,
+		GraphTimestamp: epoch + 100*uint64(time.Hour*24) + 8*uint64(time.Hour),
+		persistStore:   store,
+		custom:         custom,
+		done:           make(chan struct{}),
+		mlc:            make(chan struct{}),
+	}
+	require.False(node.hasSigner())
+
+	go node.MintLoop()
+	time.Sleep(2500 * time.Millisecond)
+	close(node.done)
+	<-node.mlc
+
+	require.Equal(int32(0), atomic.LoadInt32(&store.reads))
+	require.Len(store.attempts, 0)
+
+	node.Signer.PublicSpendKey = node.Signer.PrivateSpendKey.Public()
+	node.Signer.PrivateViewKey = node.Signer.PublicSpendKey.DeterministicHashDerive()
+	node.Signer.PublicViewKey = node.Signer.PrivateViewKey.Public()
+	require.False(node.hasSigner())
+	node.MintSigner = &testMintSigner{}
+	require.True(node.hasSigner())
+	node.MintSigner = nil
+	node.Signer = common.NewAddressFromSeed(bytes.Repeat([]byte{1}, 64))
+	require.True(node.hasSigner())
+}
+
+func TestMintOutputKeysCollision(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	payee := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	si := crypto.NewHash([]byte(payee.String() + "MINTKERNELNODE1616"))
+	seed := append(si[:], si[:]...)
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1616, common.NewInteger(2))
+	tx.AddScriptOutput([]*common.Address{&payee}, common.NewThresholdScript(1), common.NewInteger(1), seed)
+	tx.AddScriptOutput([]*common.Address{&payee}, common.NewThresholdScript(1), common.NewInteger(1), seed)
+	ver := tx.AsVersioned()
+	require.NotEqual(ver.Outputs[0].Keys[0], ver.Outputs[1].Keys[0])
+	require.Nil(checkMintOutputKeys(ver))
+
+	ver.Outputs[1].Keys = ver.Outputs[0].Keys
+	err = checkMintOutputKeys(ver)
+	require.NotNil(err)
+	require.Contains(err.Error(), "duplicated mint output key")
+
+	snap := &common.Snapshot{
+		Version:   common.SnapshotVersionCommonEncoding,
+		NodeId:    node.genesisNodes[1],
+		Timestamp: node.mintTimestamp(1616),
+	}
+	err = node.validateMintSnapshot(snap, ver)
+	require.NotNil(err)
+	require.Contains(err.Error(), "duplicated mint output key")
+}
+
+func TestMintValidationReason(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := node.Epoch + 1000*uint64(time.Hour*24) + 8*uint64(time.Hour)
+	tx := common.NewTransactionV2(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(1000, common.NewInteger(100))
+	snap := &common.Snapshot{
+		Version:   common.SnapshotVersionCommonEncoding,
+		NodeId:    crypto.NewHash([]byte("MINTVALIDATIONREASON")),
+		Timestamp: timestamp,
+	}
+	snap.Timestamp = node.mintTimestamp(MainnetMintProducerForkBatch)
+	code, err := node.MintValidationReason(snap, tx.AsVersioned())
+	require.Equal("invalid_producer", code)
+	require.NotNil(err)
+
+	snap.NodeId = node.genesisNodes[1]
+	snap.Timestamp = timestamp
+	code, err = node.MintValidationReason(snap, tx.AsVersioned())
+	require.Equal("timestamp_skip", code)
+	require.NotNil(err)
+
+	store := node.persistStore
+	node.persistStore = &testCustodianErrorStore{Store: store}
+	code, err = node.MintValidationReason(snap, tx.AsVersioned())
+	require.Equal("custodian_read_error", code)
+	require.Contains(err.Error(), "custodian read error")
+	node.persistStore = store
+
+	payee := node.NodesListWithoutState(timestamp, true)[0].Payee
+	tx.AddScriptOutput([]*common.Address{&payee}, common.NewThresholdScript(1), common.NewInteger(50), make([]byte, 64))
+	tx.AddScriptOutput([]*common.Address{&payee}, common.NewThresholdScript(1), common.NewInteger(50), make([]byte, 64))
+	ver := tx.AsVersioned()
+	ver.Outputs[1].Keys = ver.Outputs[0].Keys
+	code, err = node.MintValidationReason(snap, ver)
+	require.Equal("duplicated_keys", code)
+	require.NotNil(err)
+
+	node.networkId = crypto.NewHash([]byte("LEGACYMINTTESTNET"))
+	code, err = node.MintValidationReason(snap, ver)
+	require.Equal("legacy_disabled", code)
+	require.NotNil(err)
+}
+
+type testEmptyDomainsStore struct {
+	storage.Store
+	works int
+}
+
+func (s *testEmptyDomainsStore) ReadDomains() []*common.Domain {
+	return nil
+}
+
+func (s *testEmptyDomainsStore) ListNodeWorks(cids []crypto.Hash, day uint32) (map[crypto.Hash][2]uint64, error) {
+	s.works += 1
+	return s.Store.ListNodeWorks(cids, day)
+}
+
+func TestUniversalMintEmptyDomains(t *testing.T) {
+	require := require.New(t)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	timestamp := node.Epoch + 1617*uint64(time.Hour*24) + 8*uint64(time.Hour)
+	batch, amount := node.checkUniversalMintPossibility(timestamp, ^uint64(0), false)
+	require.Equal(1617, batch)
+	require.True(amount.Sign() > 0)
+
+	store := &testEmptyDomainsStore{Store: node.persistStore}
+	node.persistStore = store
+	versioned := node.buildUniversalMintTransaction(nil, timestamp, ^uint64(0), false)
+	require.Nil(versioned)
+	require.Equal(0, store.works)
+
+	addr := "XINYneY2gomSHxkYF62pxbNdwcdhcayxJRAeyUanJR611q5NWg4QebfFhEF3Me8qCHR8g8tD6QHPHD8naZnnn3GdRrhhiuxi"
+	custodian, _ := common.NewAddressFromString(addr)
+	cur := &common.CustodianUpdateRequest{Custodian: &custodian}
+	versioned = node.buildUniversalMintTransaction(cur, timestamp, ^uint64(0), false)
+	require.Nil(versioned)
+	require.Equal(1, store.works)
+}
+
+type testConflictStore struct {
+	storage.Store
+	conflicts int
+	writes    int
+}
+
+func (s *testConflictStore) WriteRoundWork(nodeId crypto.Hash, round uint64, snapshots []*common.SnapshotWork) error {
+	s.writes += 1
+	if s.writes <= s.conflicts {
+		return badger.ErrConflict
+	}
+	return nil
+}
+
+func TestMintWorkConflictBackoff(t *testing.T) {
+	require := require.New(t)
+
+	base, limit := 10*time.Millisecond, 40*time.Millisecond
+	require.Equal(10*time.Millisecond, conflictBackoff(base, limit, 0))
+	require.Equal(20*time.Millisecond, conflictBackoff(base, limit, 1))
+	require.Equal(40*time.Millisecond, conflictBackoff(base, limit, 2))
+	require.Equal(40*time.Millisecond, conflictBackoff(base, limit, 3))
+	require.Equal(40*time.Millisecond, conflictBackoff(base, limit, 100))
+
+	custom := &config.Custom{}
+	custom.Node.ConflictBackoffBase = 10
+	custom.Node.ConflictBackoffLimit = 40
+	store := &testConflictStore{conflicts: 5}
+	chain := &Chain{
+		node:         &Node{custom: custom},
+		persistStore: store,
+		running:      true,
+	}
+
+	start := time.Now()
+	err := chain.writeRoundWork(0, nil)
+	elapsed := time.Since(start)
+	require.Nil(err)
+	require.Equal(6, store.writes)
+	require.GreaterOrEqual(elapsed, 150*time.Millisecond)
+	require.Less(elapsed, 450*time.Millisecond)
+}
+
+type testWorkOffsetStore struct {
+	storage.Store
+	failures int
+	reads    int
+}
+
+func (s *testWorkOffsetStore) ReadWorkOffset(nodeId crypto.Hash) (uint64, error) {
+	s.reads += 1
+	if s.failures < 0 || s.reads <= s.failures {
+		return 0, fmt.Errorf("store unavailable")
+	}
+	return 7, nil
+}
+
+func TestMintWorkOffsetRetry(t *testing.T) {
+	require := require.New(t)
+
+	custom := &config.Custom{}
+	custom.Node.ConflictBackoffBase = 10
+	custom.Node.ConflictBackoffLimit = 40
+	custom.Node.WorkOffsetTimeout = 1
+	store := &testWorkOffsetStore{failures: 3}
+	chain := &Chain{
+		node:         &Node{custom: custom},
+		persistStore: store,
+		running:      true,
+		wlc:          make(chan struct{}),
+	}
+
+	round, err := chain.readWorkOffset()
+	require.Nil(err)
+	require.Equal(uint64(7), round)
+	require.Equal(4, store.reads)
+
+	store.failures, store.reads = -1, 0
+	start := time.Now()
+	_, err = chain.readWorkOffset()
+	require.NotNil(err)
+	require.Contains(err.Error(), "store unavailable")
+	require.GreaterOrEqual(time.Since(start), time.Second)
+
+	require.NotPanics(chain.AggregateMintWork)
+	_, open := <-chain.wlc
+	require.False(open)
+
+	chain.running = false
+	chain.wlc = make(chan struct{})
+	chain.AggregateMintWork()
+	_, open = <-chain.wlc
+	require.False(open)
+}
+
+type testRoundWorkStore struct {
+	storage.Store
+	chain  *Chain
+	reads  map[uint64]int
+	writes []uint64
+}
+
+func (s *testRoundWorkStore) ReadWorkOffset(nodeId crypto.Hash) (uint64, error) {
+	return 0, nil
+}
+
+// the cache round advances to 5 after idle at round 3, then stops at 5
+func (s *testRoundWorkStore) ReadSnapshotWorksForNodeRound(nodeId crypto.Hash, round uint64) ([]*common.SnapshotWork, error) {
+	s.reads[round] += 1
+	if round == 3 && s.reads[round] == 5 {
+		s.chain.State.CacheRound.Number = 5
+	}
+	if round == 5 && s.reads[round] == 5 {
+		s.chain.running = false
+	}
+	signers := []crypto.Hash{nodeId}
+	return testBuildMintSnapshots(signers, round, uint64(clock.Now().UnixNano()))[:2], nil
+}
+
+func (s *testRoundWorkStore) WriteRoundWork(nodeId crypto.Hash, round uint64, snapshots []*common.SnapshotWork) error {
+	s.writes = append(s.writes, round)
+	return nil
+}
+
+func TestMintWorkCaughtUp(t *testing.T) {
+	require := require.New(t)
+
+	store := &testRoundWorkStore{reads: make(map[uint64]int)}
+	chain := &Chain{
+		node:         &Node{custom: &config.Custom{}},
+		ChainId:      crypto.NewHash([]byte("MINTWORKCAUGHTUP")),
+		State:        &ChainState{CacheRound: &CacheRound{Number: 3}},
+		persistStore: store,
+		running:      true,
+		wlc:          make(chan struct{}),
+	}
+	store.chain = chain
+
+	var caught []uint64
+	chain.SetWorkCaughtUpHook(func(round uint64) {
+		caught = append(caught, round)
+	})
+	go chain.AggregateMintWork()
+	<-chain.wlc
+	require.Equal([]uint64{3, 5}, caught)
+}
+
+func TestMintWorkAggregationStats(t *testing.T) {
+	require := require.New(t)
+
+	store := &testRoundWorkStore{reads: make(map[uint64]int)}
+	chain := &Chain{
+		node:         &Node{custom: &config.Custom{}},
+		ChainId:      crypto.NewHash([]byte("MINTWORKAGGREGATIONSTATS")),
+		State:        &ChainState{CacheRound: &CacheRound{Number: 3}},
+		persistStore: store,
+		running:      true,
+		wlc:          make(chan struct{}),
+	}
+	store.chain = chain
+
+	rounds, snapshots := chain.AggregationStats()
+	require.Equal(uint64(0), rounds)
+	require.Equal(uint64(0), snapshots)
+
+	// the idle writes of the cache round are not counted until it advances
+	go chain.AggregateMintWork()
+	<-chain.wlc
+	require.Greater(len(store.writes), 5)
+	rounds, snapshots = chain.AggregationStats()
+	require.Equal(uint64(5), rounds)
+	require.Equal(uint64(10), snapshots)
+}
+
+func testBuildMintSnapshots(signers []crypto.Hash, round, timestamp uint64) []*common.SnapshotWork {
+	snapshots := make([]*common.SnapshotWork, 100)
+	for i := range snapshots {
+		hash := []byte(fmt.Sprintf("MW%d%d%d", round, timestamp, i))
+		s := common.SnapshotWork{
+			Timestamp: timestamp,
+			Hash:      crypto.NewHash(hash),
+			Signers:   signers,
+		}
+		snapshots[i] = &s
+	}
+	return snapshots
+}
+
+func TestMintBreakdown(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	node.IdForNetwork = node.genesisNodes[0]
+	_, _, _, err = node.MintBreakdown(1616)
+	require.NotNil(err)
+
+	addr := "XINYneY2gomSHxkYF62pxbNdwcdhcayxJRAeyUanJR611q5NWg4QebfFhEF3Me8qCHR8g8tD6QHPHD8naZnnn3GdRrhhiuxi"
+	custodian, _ := common.NewAddressFromString(addr)
+	light := common.NewAddressFromSeed(make([]byte, 64))
+
+	tx := common.NewTransactionV3(common.XINAssetId)
+	amount := common.NewIntegerFromString("100.5")
+	tx.AddUniversalMintInput(uint64(1616), amount)
+	for i := 0; i < 3; i++ {
+		seed := crypto.NewHash([]byte(fmt.Sprintf("MINTBREAKDOWN%d", i)))
+		tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewIntegerFromString("10.1"), append(seed[:], seed[:]...))
+	}
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(40), make([]byte, 64))
+	tx.AddScriptOutput([]*common.Address{&light}, common.NewThresholdScript(common.Operator64), common.NewIntegerFromString("30.2"), make([]byte, 64))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	kernel, safe, rest, err := node.MintBreakdown(1616)
+	require.Nil(err)
+	require.Equal("30.30000000", kernel.String())
+	require.Equal("40.00000000", safe.String())
+	require.Equal("30.20000000", rest.String())
+	require.Equal(amount, kernel.Add(safe).Add(rest))
+}
+
+func TestRecoverMintSigner(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+
+	node.IdForNetwork = node.genesisNodes[0]
+	_, err = node.RecoverMintSigner(1616)
+	require.NotNil(err)
+
+	light := common.NewAddressFromSeed(make([]byte, 64))
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(uint64(1616), common.NewInteger(100))
+	tx.AddScriptOutput([]*common.Address{&light}, common.NewThresholdScript(common.Operator64), common.NewInteger(100), make([]byte, 64))
+	ver := tx.AsVersioned()
+	err = node.signMintTransaction(ver)
+	require.Nil(err)
+	testWriteMintTransaction(require, node, ver)
+
+	_, err = node.RecoverMintSigner(1616)
+	require.NotNil(err)
+	require.Contains(err.Error(), "mint signer not found")
+
+	nodes := node.NodesListWithoutState(uint64(clock.Now().UnixNano()), false)
+	nodes[3].Signer = node.Signer
+	signer, err := node.RecoverMintSigner(1616)
+	require.Nil(err)
+	require.Equal(node.Signer.String(), signer.String())
+
+	batches, err := node.MintsProducedBy(nodes[3].IdForNetwork, 0, 2000)
+	require.Nil(err)
+	require.Equal([]uint64{1616}, batches)
+	batches, err = node.MintsProducedBy(nodes[3].IdForNetwork, 1617, 2000)
+	require.Nil(err)
+	require.Len(batches, 0)
+	batches, err = node.MintsProducedBy(nodes[4].IdForNetwork, 0, 2000)
+	require.Nil(err)
+	require.Len(batches, 0)
+	_, err = node.MintsProducedBy(crypto.NewHash([]byte("MINTSPRODUCEDBY")), 0, 2000)
+	require.NotNil(err)
+}
+
+func TestCumulativeBurned(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	burned, err := node.CumulativeBurned(0, 2000)
+	require.Nil(err)
+	require.Equal("0.00000000", burned.String())
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	light := common.NewAddressFromSeed(make([]byte, 64))
+	seed := func(i int) []byte {
+		s := crypto.NewHash([]byte(fmt.Sprintf("CUMULATIVEBURNED%d", i)))
+		return append(s[:], s[:]...)
+	}
+
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1616, common.NewInteger(100))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(50), seed(0))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(40), seed(1))
+	tx.AddScriptOutput([]*common.Address{&light}, common.NewThresholdScript(common.Operator64), common.NewInteger(10), seed(2))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	tx = common.NewTransactionV3(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(1617, common.NewInteger(100))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewIntegerFromString("94.5"), seed(3))
+	tx.AddScriptOutput([]*common.Address{&light}, common.NewThresholdScript(common.Operator64), common.NewIntegerFromString("5.5"), seed(4))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	tx = common.NewTransactionV3(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(1618, common.NewInteger(100))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), seed(5))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	burned, err = node.CumulativeBurned(0, 2000)
+	require.Nil(err)
+	require.Equal("15.50000000", burned.String())
+	burned, err = node.CumulativeBurned(1617, 1618)
+	require.Nil(err)
+	require.Equal("5.50000000", burned.String())
+	burned, err = node.CumulativeBurned(1618, 1618)
+	require.Nil(err)
+	require.Equal("0.00000000", burned.String())
+}
+
+func TestMintGaps(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+	node.GraphTimestamp = node.mintTimestamp(1622)
+
+	gaps, err := node.MintGaps(0, 3)
+	require.Nil(err)
+	require.Equal([]uint64{1, 2, 3}, gaps)
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	for i, batch := range []uint64{1616, 1620} {
+		tx := common.NewTransactionV3(common.XINAssetId)
+		tx.AddUniversalMintInput(batch, common.NewInteger(100))
+		seed := crypto.NewHash([]byte(fmt.Sprintf("MINTGAPS%d", i)))
+		tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), append(seed[:], seed[:]...))
+		testWriteMintTransaction(require, node, tx.AsVersioned())
+	}
+
+	gaps, err = node.MintGaps(1610, 1620)
+	require.Nil(err)
+	require.Len(gaps, 0)
+	gaps, err = node.MintGaps(1610, 1623)
+	require.Nil(err)
+	require.Equal([]uint64{1621, 1622}, gaps)
+	gaps, err = node.MintGaps(1622, 1622)
+	require.Nil(err)
+	require.Equal([]uint64{1622}, gaps)
+	gaps, err = node.MintGaps(1617, 1619)
+	require.Nil(err)
+	require.Len(gaps, 0)
+	gaps, err = node.MintGaps(1623, 1630)
+	require.Nil(err)
+	require.Len(gaps, 0)
+}
+
+func TestLastMintDistributionCache(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	dist, err := node.LastMintDistribution()
+	require.Nil(err)
+	require.Equal(uint64(0), dist.Batch)
+	require.NotNil(node.lastMintCache.dist)
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1616, common.NewInteger(100))
+	seed := crypto.NewHash([]byte("LASTMINTDISTRIBUTIONCACHE"))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), append(seed[:], seed[:]...))
+	versioned := tx.AsVersioned()
+	err = versioned.LockInputs(node.persistStore, false)
+	require.Nil(err)
+	err = node.persistStore.WriteTransaction(versioned)
+	require.Nil(err)
+	cache, err := loadHeadRoundForNode(node.persistStore, node.IdForNetwork)
+	require.Nil(err)
+	snap := &common.Snapshot{
+		Version:     common.SnapshotVersionCommonEncoding,
+		NodeId:      node.IdForNetwork,
+		RoundNumber: cache.Number,
+		Timestamp:   uint64(clock.Now().UnixNano()),
+		Signature:   &crypto.CosiSignature{Mask: 1},
+		References: &common.RoundLink{
+			Self:     cache.References.Self,
+			External: cache.References.External,
+		},
+	}
+	snap.AddSoleTransaction(versioned.PayloadHash())
+	snap.Hash = snap.PayloadHash()
+	node.TopoWrite(snap, []crypto.Hash{snap.NodeId})
+
+	dist, err = node.LastMintDistribution()
+	require.Nil(err)
+	require.Equal(uint64(0), dist.Batch)
+
+	err = node.reloadConsensusState(snap, versioned)
+	require.Nil(err)
+	require.Equal(uint64(1616), node.LastMint)
+	dist, err = node.LastMintDistribution()
+	require.Nil(err)
+	require.Equal(uint64(1616), dist.Batch)
+	require.Equal(common.NewInteger(100), dist.Amount)
+
+	node.lastMintCache = nil
+	dist, err = node.LastMintDistribution()
+	require.Nil(err)
+	require.Equal(uint64(1616), dist.Batch)
+}
+
+func TestMintReproBundle(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1616, common.NewInteger(100))
+	seed := crypto.NewHash([]byte("MINTREPROBUNDLE"))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), append(seed[:], seed[:]...))
+	versioned := tx.AsVersioned()
+	testWriteMintTransaction(require, node, versioned)
+
+	data, err := node.MintReproBundle(1616)
+	require.Nil(err)
+	var bundle mintReproBundle
+	err = json.Unmarshal(data, &bundle)
+	require.Nil(err)
+	require.Equal(node.networkId, bundle.Network)
+	require.Equal(uint64(1616), bundle.Batch)
+	require.Equal(uint64(0), bundle.Previous.Batch)
+	require.Len(bundle.Nodes, len(node.genesisNodes))
+	require.Nil(bundle.Custodian)
+	require.Len(bundle.Domains, 1)
+	require.Len(bundle.Forks, 9)
+	for _, f := range bundle.Forks {
+		require.Equal(f.Batch < MainnetMintProducerForkBatch, f.Enabled)
+	}
+	require.Equal(versioned.PayloadHash(), bundle.Expected.Hash)
+	require.Equal(hex.EncodeToString(versioned.PayloadMarshal()), bundle.Expected.Payload)
+
+	_, err = node.MintReproBundle(1617)
+	require.NotNil(err)
+}
+
+func TestExpectedMintAmount(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	ts := node.mintTimestamp(1616)
+	amount, err := node.ExpectedMintAmount(1616, ts)
+	require.Nil(err)
+	require.Equal("130716.69036912", amount.String())
+	_, err = node.ExpectedMintAmount(1617, ts)
+	require.NotNil(err)
+
+	custodian := node.NodesListWithoutState(ts, true)[0].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(1616, common.NewInteger(100))
+	seed := crypto.NewHash([]byte("EXPECTEDMINTAMOUNT"))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), append(seed[:], seed[:]...))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	ts = node.mintTimestamp(1617)
+	amount, err = node.ExpectedMintAmount(1617, ts)
+	require.Nil(err)
+	require.Equal("80.88904107", amount.String())
+
+	store := node.persistStore
+	node.persistStore = &testCustodianStore{Store: store, cur: &common.CustodianUpdateRequest{Custodian: &custodian}}
+	amount, err = node.ExpectedMintAmount(1617, ts)
+	require.Nil(err)
+	require.Equal("18686.95342732", amount.String())
+
+	mainnet := node.networkId
+	node.networkId = crypto.NewHash([]byte("LEGACYMINTTESTNET"))
+	amount, err = node.ExpectedMintAmount(1617, ts)
+	require.Nil(err)
+	require.Equal("89.87671232", amount.String())
+	node.custom.Node.MinMintAmount = "100"
+	amount, err = node.ExpectedMintAmount(1617, ts)
+	require.Nil(err)
+	require.Equal(common.Zero, amount)
+	batch, _ := node.checkUniversalMintPossibility(ts, ^uint64(0), false)
+	require.Equal(0, batch)
+	node.custom.Node.MinMintAmount = "0"
+	batch, amount = node.checkUniversalMintPossibility(ts, ^uint64(0), false)
+	require.Equal(1617, batch)
+	require.Equal("89.87671232", amount.String())
+	node.networkId = mainnet
+
+	ts = node.mintTimestamp(1619)
+	_, err = node.ExpectedMintAmount(1619, ts)
+	require.NotNil(err)
+	require.Contains(err.Error(), "not continuous")
+}
+
+type testCustodianStore struct {
+	storage.Store
+	cur *common.CustodianUpdateRequest
+}
+
+func (s *testCustodianStore) ReadCustodian(ts uint64) (*common.CustodianUpdateRequest, error) {
+	return s.cur, nil
+}
+
+type testDuplicateMintStore struct {
+	storage.Store
+	mints []*common.MintDistribution
+}
+
+func (s *testDuplicateMintStore) ReadMintDistributions(offset, count uint64) ([]*common.MintDistribution, []*common.VersionedTransaction, error) {
+	var mints []*common.MintDistribution
+	for _, m := range s.mints {
+		if m.Batch >= offset && uint64(len(mints)) < count {
+			mints = append(mints, m)
+		}
+	}
+	return mints, nil, nil
+}
+
+func TestDetectDuplicateMints(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	for i, batch := range []uint64{1616, 1620} {
+		tx := common.NewTransactionV3(common.XINAssetId)
+		tx.AddUniversalMintInput(batch, common.NewInteger(100))
+		seed := crypto.NewHash([]byte(fmt.Sprintf("DETECTDUPLICATEMINTS%d", i)))
+		tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), append(seed[:], seed[:]...))
+		testWriteMintTransaction(require, node, tx.AsVersioned())
+	}
+	duplicates, err := node.DetectDuplicateMints(0, 2000)
+	require.Nil(err)
+	require.Len(duplicates, 0)
+
+	store := &testDuplicateMintStore{}
+	for i := uint64(1); i <= 1200; i++ {
+		store.mints = append(store.mints, &common.MintDistribution{MintData: common.MintData{Batch: i}})
+		if i == 7 || i == 700 || i == 1100 {
+			store.mints = append(store.mints, &common.MintDistribution{MintData: common.MintData{Batch: i}})
+		}
+	}
+	node.persistStore = store
+	duplicates, err = node.DetectDuplicateMints(0, 2000)
+	require.Nil(err)
+	require.Equal([]uint64{7, 700, 1100}, duplicates)
+	duplicates, err = node.DetectDuplicateMints(8, 1000)
+	require.Nil(err)
+	require.Equal([]uint64{700}, duplicates)
+}
+
+func TestCustodianMintOutput(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	_, _, err = node.CustodianMintOutput(1616)
+	require.NotNil(err)
+
+	domains := node.persistStore.ReadDomains()
+	require.Len(domains, 1)
+	custodian := domains[0].Account
+	payee := node.NodesListWithoutState(node.mintTimestamp(1616), true)[3].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(uint64(1616), common.NewInteger(100))
+	tx.AddScriptOutput([]*common.Address{&payee}, common.NewThresholdScript(1), common.NewInteger(50), MintSeed(payee, "MINTKERNELNODE1616"))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(40), MintSeed(custodian, "MINTCUSTODIANACCOUNT1616"))
+	tx.AddScriptOutput([]*common.Address{&payee}, common.NewThresholdScript(common.Operator64), common.NewInteger(10), MintSeed(payee, "MINTLIGHTACCOUNT1616"))
+	versioned := tx.AsVersioned()
+	testWriteMintTransaction(require, node, versioned)
+
+	out, amount, err := node.CustodianMintOutput(1616)
+	require.Nil(err)
+	require.Equal(common.NewInteger(40), amount)
+	require.Equal(versioned.Outputs[1].Keys[0], out.Keys[0])
+
+	// a custodian request without the custodian falls back to the domain
+	store := node.persistStore
+	node.persistStore = &testCustodianStore{Store: store, cur: &common.CustodianUpdateRequest{}}
+	out, amount, err = node.CustodianMintOutput(1616)
+	require.Nil(err)
+	require.Equal(common.NewInteger(40), amount)
+	require.Equal(versioned.Outputs[1].Keys[0], out.Keys[0])
+	node.persistStore = store
+
+	tx = common.NewTransactionV3(common.XINAssetId)
+	tx.AddKernelNodeMintInputLegacy(uint64(1617), common.NewInteger(100))
+	tx.AddScriptOutput([]*common.Address{&payee}, common.NewThresholdScript(1), common.NewInteger(100), MintSeed(payee, "MINTKERNELNODE1617"))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+	_, _, err = node.CustodianMintOutput(1617)
+	require.NotNil(err)
+	require.Contains(err.Error(), "no custodian output for KERNELNODE mint")
+}
+
+func TestVerifyBatchMint(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	err = node.VerifyBatchMint(1616)
+	require.NotNil(err)
+	require.Contains(err.Error(), "mint distribution not found 1616")
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(uint64(1616), common.NewInteger(100))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), make([]byte, 64))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	err = node.VerifyBatchMint(1616)
+	require.NotNil(err)
+	require.Contains(err.Error(), "mint available at")
+	err = node.VerifyBatchMint(1617)
+	require.NotNil(err)
+	require.Contains(err.Error(), "mint distribution not found 1617")
+
+	dist, err := node.lastMintDistribution(1615)
+	require.Nil(err)
+	require.Equal(uint64(0), dist.Batch)
+	dist, err = node.lastMintDistribution(1616)
+	require.Nil(err)
+	require.Equal(uint64(1616), dist.Batch)
+	dist, err = node.lastMintDistribution(^uint64(0))
+	require.Nil(err)
+	require.Equal(uint64(1616), dist.Batch)
+}
+
+func TestMintSubsystemHealth(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.GraphTimestamp = node.mintTimestamp(1616)
+
+	report, err := node.MintSubsystemHealth()
+	require.Nil(err)
+	require.Equal(uint64(0), report.LastMintBatch)
+	require.Equal(uint64(1616), report.CurrentBatch)
+	require.Equal(uint64(0), report.WorkOffsetLag)
+	require.Equal(len(node.genesisNodes), report.AcceptedNodes)
+	require.False(report.Accepted)
+
+	node.IdForNetwork = node.genesisNodes[0]
+	custodian := node.NodesListWithoutState(node.GraphTimestamp, true)[0].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(1616, common.NewInteger(100))
+	seed := crypto.NewHash([]byte("MINTSUBSYSTEMHEALTH"))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(100), append(seed[:], seed[:]...))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	report, err = node.MintSubsystemHealth()
+	require.Nil(err)
+	require.Equal(uint64(1616), report.LastMintBatch)
+	require.True(report.Accepted)
+}
+
+func TestRebuildMintOutputs(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	batch := testWriteMintWorks(require, node)
+	ts := node.mintTimestamp(batch)
+	versioned := node.buildLegacyKerneNodeMintTransaction(ts, ^uint64(0), false)
+	require.NotNil(versioned)
+	testWriteMintTransaction(require, node, versioned)
+
+	dist, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
+	require.Nil(err)
+	require.Equal(batch, dist.Batch)
+	outputs, err := node.RebuildMintOutputs(dist, ts)
+	require.Nil(err)
+	require.Len(outputs, len(versioned.Outputs))
+	for i, o := range outputs {
+		require.Equal(versioned.Outputs[i].Amount, o.Amount)
+		require.Equal(versioned.Outputs[i].Keys, o.Keys)
+		require.Equal(versioned.Outputs[i].Mask, o.Mask)
+	}
+
+	dist.Amount = dist.Amount.Add(common.NewInteger(1))
+	_, err = node.RebuildMintOutputs(dist, ts)
+	require.NotNil(err)
+	require.Contains(err.Error(), "mint distribution mismatch")
+	dist.Group = "INVALID"
+	_, err = node.RebuildMintOutputs(dist, ts)
+	require.NotNil(err)
+	require.Contains(err.Error(), "invalid mint group")
+}
+
+func TestMintValidationConcurrent(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	internal.ToggleMockRunAggregators(true)
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	batch := testWriteMintWorks(require, node)
+	ts := node.mintTimestamp(batch)
+	versioned := node.buildLegacyKerneNodeMintTransaction(ts, ^uint64(0), false)
+	require.NotNil(versioned)
+	testWriteMintTransaction(require, node, versioned)
+	versioned = node.buildLegacyKerneNodeMintTransaction(ts, ^uint64(0), true)
+	require.NotNil(versioned)
+	versioned.PayloadHash()
+
+	// the works of the day before are not final yet, so nothing is cached
+	snap := &common.Snapshot{NodeId: node.IdForNetwork, Timestamp: ts}
+	err = node.validateMintSnapshot(snap, versioned)
+	require.Nil(err)
+	require.Nil(node.lastMintCache.rebuilt)
+	day := ts / (uint64(time.Hour) * 24)
+	for _, id := range node.genesisNodes {
+		node.getChain(id).State.FinalRound.Start = day * uint64(time.Hour) * 24
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = node.validateMintSnapshot(snap, versioned)
+		}(i)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		require.Nil(err)
+	}
+	require.Len(node.lastMintCache.rebuilt, 1)
+
+	node.resetLastMintDistribution()
+	require.Nil(node.lastMintCache.rebuilt)
+	err = node.validateMintSnapshot(snap, versioned)
+	require.Nil(err)
+	require.Len(node.lastMintCache.rebuilt, 1)
+
+	// a rebuild racing the reset is not stored
+	c := node.lastMintCache
+	version := c.version
+	node.resetLastMintDistribution()
+	require.Equal(version+1, c.version)
+	c.version = version
+	err = node.validateMintSnapshot(snap, versioned)
+	require.Nil(err)
+	require.Len(node.lastMintCache.rebuilt, 1)
+
+	// the same batch is cached once, and the cache is reset once full
+	snap.Timestamp = ts + uint64(time.Minute)
+	err = node.validateMintSnapshot(snap, versioned)
+	require.Nil(err)
+	require.Len(node.lastMintCache.rebuilt, 1)
+	node.lastMintCache.rebuilt = make(map[string]*common.VersionedTransaction)
+	for i := 0; i < MintRebuiltCacheLimit; i++ {
+		node.lastMintCache.rebuilt[fmt.Sprint(i)] = versioned
+	}
+	err = node.validateMintSnapshot(snap, versioned)
+	require.Nil(err)
+	require.Len(node.lastMintCache.rebuilt, 1)
+
+	// the cache key covers the works of the day before, so the cached
+	// mint is not reused after the works change
+	key, ok := node.mintCacheInputs(snap.Timestamp)
+	require.True(ok)
+	snapshots := testBuildMintSnapshots(node.genesisNodes, 2, ts-uint64(time.Hour*24))
+	err = node.persistStore.WriteRoundWork(node.genesisNodes[1], 2, snapshots)
+	require.Nil(err)
+	changed, ok := node.mintCacheInputs(snap.Timestamp)
+	require.True(ok)
+	require.NotEqual(key, changed)
+	err = node.validateMintSnapshot(snap, versioned)
+	require.NotNil(err)
+	require.Contains(err.Error(), "malformed mint transaction")
+}
+
+func TestMinMintAmount(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(1616), true)[0].Payee
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(uint64(36500), common.NewInteger(1))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(1), make([]byte, 64))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	node.custom.Node.MinMintAmount = "0.01"
+	require.Equal(common.Zero, node.minMintAmount())
+	node.networkId = crypto.NewHash([]byte("MINMINTAMOUNTTESTNET"))
+	require.Equal("0.01000000", node.minMintAmount().String())
+	total := mintBatchTotal(36501)
+	require.Equal("0.00363854", total.String())
+
+	for b, expect := range map[int]string{36501: "0", 36502: "0", 36503: "0.01091562", 36504: "0.01455416"} {
+		timestamp := node.Epoch + uint64(b)*uint64(time.Hour*24) + 8*uint64(time.Hour)
+		batch, amount := node.checkUniversalMintPossibility(timestamp, ^uint64(0), false)
+		require.Equal(common.NewIntegerFromString(expect), amount)
+		if amount.Sign() > 0 {
+			require.Equal(b, batch)
+			require.Equal(total.Mul(b-36500), amount)
+		} else {
+			require.Equal(0, batch)
+		}
+	}
+
+	node.custom.Node.MinMintAmount = "0"
+	timestamp := node.Epoch + 36501*uint64(time.Hour*24) + 8*uint64(time.Hour)
+	batch, amount := node.checkUniversalMintPossibility(timestamp, ^uint64(0), false)
+	require.Equal(36501, batch)
+	require.Equal(total, amount)
+}
+
+func TestMintParametersConcurrent(t *testing.T) {
+	require := require.New(t)
+
+	totals := make([]common.Integer, 8)
+	var wg sync.WaitGroup
+	for i := range totals {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			batch := i * MintYearBatches
+			totals[i] = mintBatchTotal(batch)
+			pool := poolSizeUniversal(batch)
+			if i > 0 {
+				require.True(pool.Cmp(MintPool) < 0)
+			}
+			require.True(PoolDepletionEstimate(pool.Div(2)) > batch)
+		}(i)
+	}
+	wg.Wait()
+	for i := range totals {
+		require.Equal(mintBatchTotal(i*MintYearBatches), totals[i])
+	}
+}
+
+func TestExportMintDistributionCSV(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	accepted := node.NodesListWithoutState(node.mintTimestamp(1616), true)
+	require.Len(accepted, 15)
+	cn := accepted[3]
+	custodian := accepted[0].Payee
+	light := common.NewAddressFromSeed(make([]byte, 64))
+
+	tx := common.NewTransactionV3(common.XINAssetId)
+	tx.AddUniversalMintInput(uint64(1616), common.NewIntegerFromString("100.5"))
+	si := crypto.NewHash([]byte(cn.Signer.String() + "MINTKERNELNODE1616"))
+	tx.AddScriptOutput([]*common.Address{&cn.Payee}, common.NewThresholdScript(1), common.NewIntegerFromString("30.3"), append(si[:], si[:]...))
+	tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewInteger(40), make([]byte, 64))
+	tx.AddScriptOutput([]*common.Address{&light}, common.NewThresholdScript(common.Operator64), common.NewIntegerFromString("30.2"), make([]byte, 64))
+	testWriteMintTransaction(require, node, tx.AsVersioned())
+
+	var buf bytes.Buffer
+	err = node.ExportMintDistributionCSV(&buf, 1617, 2000)
+	require.Nil(err)
+	require.Equal("batch,node_id,payee,work,amount,group\n", buf.String())
+
+	buf.Reset()
+	err = node.ExportMintDistributionCSV(&buf, 0, 1616)
+	require.Nil(err)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(lines, 4)
+	require.Equal("batch,node_id,payee,work,amount,group", lines[0])
+	require.Equal(fmt.Sprintf("1616,%s,%s,0.00000000,30.30000000,UNIVERSAL", cn.IdForNetwork, cn.Payee), lines[1])
+	require.Equal("1616,,,,40.00000000,UNIVERSAL", lines[2])
+	require.Equal("1616,,,,30.20000000,UNIVERSAL", lines[3])
+
+	// the work is the same score as the distribution, without truncation
+	node.persistStore = &testNodeWorksStore{Store: node.persistStore, works: [2]uint64{1, 3}}
+	buf.Reset()
+	err = node.ExportMintDistributionCSV(&buf, 0, 1616)
+	require.Nil(err)
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(fmt.Sprintf("1616,%s,%s,4.20000000,30.30000000,UNIVERSAL", cn.IdForNetwork, cn.Payee), lines[1])
+}
+
+type testNodeWorksStore struct {
+	storage.Store
+	works [2]uint64
+}
+
+func (s *testNodeWorksStore) ListNodeWorks(cids []crypto.Hash, day uint32) (map[crypto.Hash][2]uint64, error) {
+	works := make(map[crypto.Hash][2]uint64)
+	for _, id := range cids {
+		works[id] = s.works
+	}
+	return works, nil
+}
+
+func TestVerifyYearBoundaries(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	checks, err := node.VerifyYearBoundaries()
+	require.Nil(err)
+	require.Len(checks, 0)
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(365), true)[0].Payee
+	for batch, amount := range []string{"50000", "1"} {
+		tx := common.NewTransactionV3(common.XINAssetId)
+		tx.AddUniversalMintInput(uint64(365+batch*435), common.NewIntegerFromString(amount))
+		seed := crypto.NewHash([]byte(fmt.Sprintf("YEARBOUNDARY%d", batch)))
+		tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewIntegerFromString(amount), append(seed[:], seed[:]...))
+		testWriteMintTransaction(require, node, tx.AsVersioned())
+	}
+
+	checks, err = node.VerifyYearBoundaries()
+	require.Nil(err)
+	require.Len(checks, 2)
+	require.Equal(1, checks[0].Year)
+	require.Equal(uint64(365), checks[0].Batch)
+	require.Equal("450000.00000000", checks[0].Expected.String())
+	require.Equal("450000.00000000", checks[0].Actual.String())
+	require.True(checks[0].Matched)
+	require.Equal(2, checks[1].Year)
+	require.Equal(uint64(730), checks[1].Batch)
+	require.Equal("405000.00000000", checks[1].Expected.String())
+	require.Equal("450000.00000000", checks[1].Actual.String())
+	require.False(checks[1].Matched)
+}
+
+func TestRemainingYearEmission(t *testing.T) {
+	require := require.New(t)
+	logger.SetLevel(0)
+
+	root, err := os.MkdirTemp("", "mixin-mint-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	node := setupTestNode(require, root)
+	require.NotNil(node)
+	node.IdForNetwork = node.genesisNodes[0]
+
+	remaining, err := node.RemainingYearEmission(node.mintTimestamp(100))
+	require.Nil(err)
+	require.Equal("50000.00000000", remaining.String())
+
+	custodian := node.NodesListWithoutState(node.mintTimestamp(365), true)[0].Payee
+	for batch, amount := range []string{"50000", "1"} {
+		tx := common.NewTransactionV3(common.XINAssetId)
+		tx.AddUniversalMintInput(uint64(365+batch*435), common.NewIntegerFromString(amount))
+		seed := crypto.NewHash([]byte(fmt.Sprintf("YEAREMISSION%d", batch)))
+		tx.AddScriptOutput([]*common.Address{&custodian}, common.NewThresholdScript(1), common.NewIntegerFromString(amount), append(seed[:], seed[:]...))
+		testWriteMintTransaction(require, node, tx.AsVersioned())
+	}
+
+	for _, c := range []struct {
+		batch     uint64
+		remaining string
+	}{
+		{100, "0.00000000"},
+		{365, "0.00000000"},
+		{366, "45000.00000000"},
+		{730, "45000.00000000"},
+		{800, "40499.00000000"},
+		{1095, "40499.00000000"},
+		{1096, "36450.00000000"},
+	} {
+		remaining, err = node.RemainingYearEmission(node.mintTimestamp(c.batch))
+		require.Nil(err)
+		require.Equal(c.remaining, remaining.String())
+	}
+}
+
+// the works of all genesis nodes for today and the day before, and the
+// returned batch of today is ready to mint
+func testWriteMintWorks(require *require.Assertions, node *Node) uint64 {
+	timestamp := uint64(clock.Now().UnixNano())
+	day := timestamp / (uint64(time.Hour) * 24)
+	batch := day - node.Epoch/(uint64(time.Hour)*24)
+	for round := uint64(0); round < 2; round++ {
+		ts := timestamp - (1-round)*uint64(time.Hour*24)
+		snapshots := testBuildMintSnapshots(node.genesisNodes, round, ts)
+		for _, id := range node.genesisNodes {
+			err := node.persistStore.WriteRoundWork(id, round, snapshots)
+			require.Nil(err)
+		}
+	}
+	for _, id := range node.genesisNodes {
+		err := node.persistStore.WriteRoundSpaceAndState(&common.RoundSpace{
+			NodeId: id,
+			Batch:  batch,
+			Round:  1,
+		})
+		require.Nil(err)
+	}
+	return batch
+}
+
+func testWriteMintTransaction(require *require.Assertions, node *Node, versioned *common.VersionedTransaction) {
+	err := versioned.LockInputs(node.persistStore, false)
+	require.Nil(err)
+	err = node.persistStore.WriteTransaction(versioned)
+	require.Nil(err)
+
+	cache, err := loadHeadRoundForNode(node.persistStore, node.IdForNetwork)
+	require.Nil(err)
+	require.NotNil(cache)
+	snap := &common.Snapshot{
+		Version:     common.SnapshotVersionCommonEncoding,
+		NodeId:      node.IdForNetwork,
+		RoundNumber: cache.Number,
+		Timestamp:   uint64(clock.Now().UnixNano()),
+		Signature:   &crypto.CosiSignature{Mask: 1},
+		References: &common.RoundLink{
+			Self:     cache.References.Self,
+			External: cache.References.External,
+		},
+	}
+	snap.AddSoleTransaction(versioned.PayloadHash())
+	snap.Hash = snap.PayloadHash()
+	node.TopoWrite(snap, []crypto.Hash{snap.NodeId})
+	err = node.reloadConsensusState(snap, versioned)
+	require.Nil(err)
 }
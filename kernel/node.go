@@ -3,6 +3,7 @@ package kernel
 import (
 	"encoding/binary"
 	"fmt"
+	"path/filepath"
 	"sort"
 	"sync"
 	"time"
@@ -31,11 +32,17 @@ type Node struct {
 	Epoch          uint64
 	LastMint       uint64
 
+	MintSigner        MintSigner
+	MintPreCommit     func(tx *common.VersionedTransaction) error
+	AllowedCustodians []common.Address
+
 	chains                     *chainsMap
 	allNodesSortedWithState    []*CNode
 	nodeStateSequences         []*NodeStateSequence
 	acceptedNodeStateSequences []*NodeStateSequence
 	chain                      *Chain
+	lastMintCache              *mintDistributionCache
+	nodesListCache             *nodesListCache
 
 	genesisNodesMap map[crypto.Hash]bool
 	genesisNodes    []crypto.Hash
@@ -69,9 +76,26 @@ type CNode struct {
 }
 
 func SetupNode(custom *config.Custom, persistStore storage.Store, cacheStore *ristretto.Cache, addr string, dir string) (*Node, error) {
-	var node = &Node{
+	node := newNode(custom, persistStore, cacheStore, addr, dir)
+	node.loadNodeConfig()
+
+	gns, err := readGenesis(filepath.Join(dir, "genesis.json"))
+	if err != nil {
+		return nil, fmt.Errorf("LoadGenesis(%s) => %v", dir, err)
+	}
+	err = node.setup(gns)
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func newNode(custom *config.Custom, persistStore storage.Store, cacheStore *ristretto.Cache, addr string, dir string) *Node {
+	return &Node{
 		SyncPoints:      &syncMap{mutex: new(sync.RWMutex), m: make(map[crypto.Hash]*network.SyncPoint)},
 		chains:          &chainsMap{m: make(map[crypto.Hash]*Chain)},
+		lastMintCache:   &mintDistributionCache{},
+		nodesListCache:  &nodesListCache{},
 		genesisNodesMap: make(map[crypto.Hash]bool),
 		persistStore:    persistStore,
 		cacheStore:      cacheStore,
@@ -84,48 +108,64 @@ func SetupNode(custom *config.Custom, persistStore storage.Store, cacheStore *ri
 		mlc:             make(chan struct{}),
 		cqc:             make(chan struct{}),
 	}
+}
 
-	node.loadNodeConfig()
+func (node *Node) setup(gns *Genesis) error {
+	err := node.loadState(gns)
+	if err != nil {
+		return err
+	}
+
+	node.chains.RLock()
+	for _, chain := range node.chains.m {
+		chain.bootLoops()
+	}
+	node.chains.RUnlock()
+	node.chain = node.BootChain(node.IdForNetwork)
 
+	logger.Printf("Listen:\t%s\n", node.addr)
+	logger.Printf("Signer:\t%s\n", node.Signer.String())
+	logger.Printf("Network:\t%s\n", node.networkId.String())
+	logger.Printf("Node Id:\t%s\n", node.IdForNetwork.String())
+	logger.Printf("Topology:\t%d\n", node.TopoCounter.seq)
+	return nil
+}
+
+// load the genesis, consensus nodes and chain states without booting any
+// chain loops
+func (node *Node) loadState(gns *Genesis) error {
 	mint, err := node.persistStore.ReadLastMintDistribution(^uint64(0))
 	if err != nil {
-		return nil, fmt.Errorf("ReadLastMintDistribution() => %v", err)
+		return fmt.Errorf("ReadLastMintDistribution() => %v", err)
 	}
 	node.LastMint = mint.Batch
 
-	err = node.LoadGenesis(dir)
+	err = node.loadGenesis(gns)
 	if err != nil {
-		return nil, fmt.Errorf("LoadGenesis(%s) => %v", dir, err)
+		return fmt.Errorf("LoadGenesis(%s) => %v", node.configDir, err)
 	}
-	node.TopoCounter = node.getTopologyCounter(persistStore)
+	node.TopoCounter = node.getTopologyCounter(node.persistStore)
 
 	logger.Println("Validating graph entries...")
 	start := clock.Now()
 	total, invalid, err := node.persistStore.ValidateGraphEntries(node.networkId, 10)
 	if err != nil {
-		return nil, fmt.Errorf("ValidateGraphEntries(%s) => %v", node.networkId, err)
+		return fmt.Errorf("ValidateGraphEntries(%s) => %v", node.networkId, err)
 	} else if invalid > 0 {
-		return nil, fmt.Errorf("validate graph with %d/%d invalid entries", invalid, total)
+		return fmt.Errorf("validate graph with %d/%d invalid entries", invalid, total)
 	}
 	logger.Printf("Validate graph with %d total entries in %s\n", total, clock.Now().Sub(start).String())
 
 	err = node.LoadConsensusNodes()
 	if err != nil {
-		return nil, fmt.Errorf("LoadConsensusNodes() => %v", err)
+		return fmt.Errorf("LoadConsensusNodes() => %v", err)
 	}
 
 	err = node.LoadAllChainsAndGraphTimestamp(node.persistStore, node.networkId)
 	if err != nil {
-		return nil, fmt.Errorf("LoadAllChainsAndGraphTimestamp() => %v", err)
+		return fmt.Errorf("LoadAllChainsAndGraphTimestamp() => %v", err)
 	}
-	node.chain = node.BootChain(node.IdForNetwork)
-
-	logger.Printf("Listen:\t%s\n", addr)
-	logger.Printf("Signer:\t%s\n", node.Signer.String())
-	logger.Printf("Network:\t%s\n", node.networkId.String())
-	logger.Printf("Node Id:\t%s\n", node.IdForNetwork.String())
-	logger.Printf("Topology:\t%d\n", node.TopoCounter.seq)
-	return node, nil
+	return nil
 }
 
 func (node *Node) loadNodeConfig() {
@@ -169,6 +209,103 @@ func (node *Node) NodesListWithoutState(threshold uint64, acceptedOnly bool) []*
 	return nil
 }
 
+type nodesListCache struct {
+	sync.RWMutex
+	version uint64
+	lists   map[string][]*CNode
+}
+
+func (node *Node) AcceptedNodes(ts uint64) ([]*CNode, error) {
+	nodes := node.acceptedNodesByDay(ts)
+	if len(nodes) == 0 {
+		return nil, fmt.Errorf("no accepted nodes at %d", ts)
+	}
+	return nodes, nil
+}
+
+// the accepted nodes are filtered from the cached list without state, and
+// they are copied to have the same consensus index as NodesListWithoutState
+func (node *Node) acceptedNodesByDay(ts uint64) []*CNode {
+	var accepted []*CNode
+	for _, n := range node.nodesListByDay(ts, false) {
+		if n.State != common.NodeStateAccepted {
+			continue
+		}
+		cn := *n
+		cn.ConsensusIndex = len(accepted)
+		accepted = append(accepted, &cn)
+	}
+	return accepted
+}
+
+// the list with state is all the node states before the timestamp, and the
+// list without state is the last state of each node, they are the same for
+// all timestamps of a day only when no node state changes in the day,
+// otherwise the list is not cached for the day
+func (node *Node) nodesListByDay(ts uint64, withState bool) []*CNode {
+	c := node.nodesListCache
+	if c == nil {
+		return node.nodesList(ts, withState)
+	}
+	day := ts / uint64(time.Hour*24)
+	key := fmt.Sprintf("%t:%d", withState, day)
+	c.RLock()
+	nodes, found := c.lists[key]
+	version := c.version
+	c.RUnlock()
+	if found {
+		return nodes
+	}
+
+	nodes = node.nodesList(ts, withState)
+	for _, seq := range node.nodeStateSequences {
+		if seq.Timestamp/uint64(time.Hour*24) == day {
+			return nodes
+		}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if c.version != version {
+		return nodes
+	}
+	if c.lists == nil {
+		c.lists = make(map[string][]*CNode)
+	}
+	c.lists[key] = nodes
+	return nodes
+}
+
+func (node *Node) nodesList(ts uint64, withState bool) []*CNode {
+	if !withState {
+		return node.NodesListWithoutState(ts, false)
+	}
+	all := node.allNodesSortedWithState
+	i := sort.Search(len(all), func(i int) bool { return all[i].Timestamp >= ts })
+	return all[:i]
+}
+
+func (node *Node) nodesListVersion() uint64 {
+	c := node.nodesListCache
+	if c == nil {
+		return 0
+	}
+	c.RLock()
+	defer c.RUnlock()
+	return c.version
+}
+
+func (node *Node) resetNodesListCache() {
+	c := node.nodesListCache
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.version += 1
+	c.lists = nil
+}
+
 func (node *Node) nodeSequenceWithoutState(threshold uint64, acceptedOnly bool) []*CNode {
 	filter := make(map[crypto.Hash]*CNode)
 	for _, n := range node.allNodesSortedWithState {
@@ -321,6 +458,7 @@ func (node *Node) LoadConsensusNodes() error {
 	node.allNodesSortedWithState = cnodes
 	node.nodeStateSequences = node.buildNodeStateSequences(cnodes, false)
 	node.acceptedNodeStateSequences = node.buildNodeStateSequences(cnodes, true)
+	node.resetNodesListCache()
 	return nil
 }
 
@@ -343,6 +481,25 @@ func (node *Node) NewTransaction(assetId crypto.Hash) *common.Transaction {
 	return common.NewTransactionV3(assetId)
 }
 
+// the mint transaction of a network without the fork history could start at
+// a modern version, so the configured version is used except on mainnet, and
+// an unset version keeps the same version as all other transactions
+func (node *Node) newMintTransaction() *common.Transaction {
+	if node.isMainnet() || node.custom == nil {
+		return node.NewTransaction(common.XINAssetId)
+	}
+	switch node.custom.Node.MintTransactionVersion {
+	case common.TxVersionCommonEncoding:
+		return common.NewTransactionV2(common.XINAssetId)
+	case common.TxVersionBlake3Hash:
+		return common.NewTransactionV3(common.XINAssetId)
+	case common.TxVersionReferences:
+		return common.NewTransactionV4(common.XINAssetId)
+	default:
+		return node.NewTransaction(common.XINAssetId)
+	}
+}
+
 func (node *Node) PingNeighborsFromConfig() error {
 	gossip, metric := node.custom.Network.GossipNeighbors, node.custom.Network.Metric
 	node.Peer = network.NewPeer(node, node.IdForNetwork, node.addr, gossip, metric)
@@ -374,6 +531,11 @@ func (node *Node) NetworkId() crypto.Hash {
 	return node.networkId
 }
 
+// the node epoch is in unix nanoseconds, while the genesis epoch is in seconds
+func (node *Node) EpochTime() time.Time {
+	return time.Unix(0, int64(node.Epoch)).UTC()
+}
+
 func (node *Node) Uptime() time.Duration {
 	return clock.Now().Sub(node.startAt)
 }
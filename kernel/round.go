@@ -43,12 +43,6 @@ func (node *Node) LoadAllChainsAndGraphTimestamp(store storage.Store, networkId
 		}
 	}
 	logger.Printf("node.LoadAllChainsAndGraphTimestamp(%s) => %d %d", networkId, len(nodes), node.GraphTimestamp)
-
-	node.chains.RLock()
-	for _, chain := range node.chains.m {
-		chain.bootLoops()
-	}
-	node.chains.RUnlock()
 	return nil
 }
 
@@ -9,8 +9,8 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 )
 
-func (node *Node) buildMintTransactionV1(timestamp uint64, validateOnly bool) *common.VersionedTransaction {
-	batch, amount := node.checkLegacyMintPossibility(timestamp, validateOnly)
+func (node *Node) buildMintTransactionV1(timestamp, last uint64, validateOnly bool) *common.VersionedTransaction {
+	batch, amount := node.checkLegacyMintPossibility(timestamp, last, validateOnly)
 	if amount.Sign() <= 0 || batch <= 0 {
 		return nil
 	}
@@ -19,7 +19,7 @@ func (node *Node) buildMintTransactionV1(timestamp uint64, validateOnly bool) *c
 		return node.legacyMintTransaction(timestamp, batch, amount)
 	}
 
-	accepted := node.NodesListWithoutState(timestamp, true)
+	accepted := node.acceptedNodesByDay(timestamp)
 	mints, err := node.distributeKernelMintByWorks(accepted, amount, timestamp)
 	if err != nil {
 		logger.Printf("buildMintTransaction ERROR %s\n", err.Error())
@@ -55,7 +55,7 @@ func (node *Node) buildMintTransactionV1(timestamp uint64, validateOnly bool) *c
 }
 
 func (node *Node) legacyMintTransaction(timestamp uint64, batch int, amount common.Integer) *common.VersionedTransaction {
-	nodes := node.NodesListWithoutState(timestamp, true)
+	nodes := node.acceptedNodesByDay(timestamp)
 	sort.Slice(nodes, func(i, j int) bool {
 		a := nodes[i].IdForNetwork
 		b := nodes[j].IdForNetwork
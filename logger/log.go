@@ -24,6 +24,10 @@ func SetLevel(l int) {
 	level = l
 }
 
+func Enabled(l int) bool {
+	return level >= l
+}
+
 func SetFilter(pattern string) error {
 	if pattern == "" {
 		return nil
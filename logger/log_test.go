@@ -46,6 +46,13 @@ func TestLogger(t *testing.T) {
 	out = filterOutput("ethereum or bitcoin %d", time.Now().UnixNano())
 	require.NotContains(out, "mixin")
 
+	SetLevel(INFO)
+	require.True(Enabled(INFO))
+	require.False(Enabled(VERBOSE))
+	SetLevel(VERBOSE)
+	require.True(Enabled(VERBOSE))
+	require.False(Enabled(DEBUG))
+
 	level = 0
 	filter = nil
 }
@@ -18,7 +18,7 @@ func getInfo(store storage.Store, node *kernel.Node) (map[string]any, error) {
 		"node":      node.IdForNetwork,
 		"version":   config.BuildVersion,
 		"uptime":    node.Uptime().String(),
-		"epoch":     time.Unix(0, int64(node.Epoch)),
+		"epoch":     node.EpochTime(),
 		"timestamp": time.Unix(0, int64(node.GraphTimestamp)),
 	}
 	pool, err := node.PoolSize()
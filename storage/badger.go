@@ -36,6 +36,24 @@ func NewBadgerStore(custom *config.Custom, dir string) (*BadgerStore, error) {
 	}, nil
 }
 
+func NewMemoryStore(custom *config.Custom) (*BadgerStore, error) {
+	snapshotsDB, err := openMemoryDB()
+	if err != nil {
+		return nil, err
+	}
+	cacheDB, err := openMemoryDB()
+	if err != nil {
+		return nil, err
+	}
+	return &BadgerStore{
+		custom:      custom,
+		snapshotsDB: snapshotsDB,
+		cacheDB:     cacheDB,
+		mutex:       new(sync.RWMutex),
+		closing:     false,
+	}, nil
+}
+
 func (store *BadgerStore) Close() error {
 	store.closing = true
 	err := store.snapshotsDB.Close()
@@ -45,6 +63,13 @@ func (store *BadgerStore) Close() error {
 	return store.cacheDB.Close()
 }
 
+func openMemoryDB() (*badger.DB, error) {
+	opts := badger.DefaultOptions("").WithInMemory(true)
+	opts = opts.WithMetricsEnabled(false)
+	opts = opts.WithLoggingLevel(badger.WARNING)
+	return badger.Open(opts)
+}
+
 func openDB(dir string, sync bool, custom *config.Custom) (*badger.DB, error) {
 	opts := badger.DefaultOptions(dir)
 	opts = opts.WithSyncWrites(sync)
@@ -7,6 +7,8 @@ import (
 	"github.com/dgraph-io/badger/v4"
 )
 
+// the genesis rounds, snapshots and transactions are written in a single
+// transaction with the loaded check, so there is never a partial genesis
 func (s *BadgerStore) LoadGenesis(rounds []*common.Round, snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.VersionedTransaction) error {
 	txn := s.snapshotsDB.NewTransaction(true)
 	defer txn.Discard()
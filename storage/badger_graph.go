@@ -95,6 +95,34 @@ func readSnapshotsForNodeRound(txn *badger.Txn, nodeId crypto.Hash, round uint64
 	return snapshots, nil
 }
 
+// the highest round number with any snapshot of the node, and a removed
+// node can never have snapshots finalized after the removal, so it is also
+// the last round of a removed node
+func (s *BadgerStore) ReadLastSnapshotRound(nodeId crypto.Hash) (uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	last := crypto.Hash{}
+	for i := range last {
+		last[i] = 0xff
+	}
+	key := graphSnapshotKey(nodeId, ^uint64(0), last)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = true
+	opts.Prefix = key[:len(graphPrefixSnapshot)+len(nodeId)]
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(key)
+	if !it.Valid() {
+		return 0, fmt.Errorf("no snapshots for node %s", nodeId)
+	}
+	off := len(graphPrefixSnapshot) + len(nodeId)
+	round := it.Item().Key()[off : off+8]
+	return binary.BigEndian.Uint64(round), nil
+}
+
 func (s *BadgerStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder, signers []crypto.Hash) error {
 	logger.Debugf("BadgerStore.WriteSnapshot(%v)", snap.Snapshot)
 	s.mutex.Lock()
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"encoding/binary"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/dgraph-io/badger/v4"
+)
+
+const (
+	cachePrefixMintAttempt = "MINTATTEMPT"
+	cacheKeyMintProposal   = "MINTPROPOSAL"
+)
+
+func (s *BadgerStore) WriteMintAttempt(attempt *common.MintAttempt, limit int) error {
+	return s.cacheDB.Update(func(txn *badger.Txn) error {
+		key := cacheMintAttemptKey(attempt.Timestamp)
+		err := txn.Set(key, attempt.Marshal())
+		if err != nil {
+			return err
+		}
+
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		opts.Reverse = true
+		opts.Prefix = []byte(cachePrefixMintAttempt)
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		var stale [][]byte
+		count := 0
+		for it.Seek(cacheMintAttemptKey(^uint64(0))); it.Valid(); it.Next() {
+			count += 1
+			if count > limit {
+				stale = append(stale, it.Item().KeyCopy(nil))
+			}
+		}
+		for _, k := range stale {
+			err := txn.Delete(k)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BadgerStore) ListMintAttempts() ([]*common.MintAttempt, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Prefix = []byte(cachePrefixMintAttempt)
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	attempts := make([]*common.MintAttempt, 0)
+	for it.Seek(cacheMintAttemptKey(0)); it.Valid(); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		attempt, err := common.UnmarshalMintAttempt(val)
+		if err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, attempt)
+	}
+	return attempts, nil
+}
+
+func (s *BadgerStore) ReadLastMintProposal() (uint64, error) {
+	txn := s.cacheDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return graphReadUint64(txn, []byte(cacheKeyMintProposal))
+}
+
+func (s *BadgerStore) WriteLastMintProposal(batch uint64) error {
+	return s.cacheDB.Update(func(txn *badger.Txn) error {
+		return graphWriteUint64(txn, []byte(cacheKeyMintProposal), batch)
+	})
+}
+
+func cacheMintAttemptKey(ts uint64) []byte {
+	key := []byte(cachePrefixMintAttempt)
+	return binary.BigEndian.AppendUint64(key, ts)
+}
@@ -69,6 +69,28 @@ func (s *BadgerStore) ReadNodeRoundSpacesForBatch(nodeId crypto.Hash, batch uint
 	return spaces, nil
 }
 
+func (s *BadgerStore) ListNodeRoundSpaceBatches(nodeId crypto.Hash) ([]uint64, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	prefix := append([]byte(graphPrefixSpaceQueue), nodeId[:]...)
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Prefix = prefix
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var batches []uint64
+	for it.Seek(prefix); it.Valid(); it.Next() {
+		key := it.Item().Key()
+		batch := binary.BigEndian.Uint64(key[len(prefix) : len(prefix)+8])
+		if n := len(batches); n == 0 || batches[n-1] != batch {
+			batches = append(batches, batch)
+		}
+	}
+	return batches, nil
+}
+
 func (s *BadgerStore) ReadRoundSpaceCheckpoint(nodeId crypto.Hash) (uint64, uint64, error) {
 	txn := s.snapshotsDB.NewTransaction(false)
 	defer txn.Discard()
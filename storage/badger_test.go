@@ -1,9 +1,11 @@
 package storage
 
 import (
+	"fmt"
 	"os"
 	"testing"
 
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/dgraph-io/badger/v4"
 	"github.com/stretchr/testify/require"
@@ -33,3 +35,42 @@ func TestBadger(t *testing.T) {
 	err = store.Close()
 	require.Nil(err)
 }
+
+func TestMintAttempts(t *testing.T) {
+	require := require.New(t)
+	custom, err := config.Initialize("../config/config.example.toml")
+	require.Nil(err)
+
+	root, err := os.MkdirTemp("", "mixin-badger-test")
+	require.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(custom, root)
+	require.Nil(err)
+	defer store.Close()
+
+	attempts, err := store.ListMintAttempts()
+	require.Nil(err)
+	require.Len(attempts, 0)
+
+	for i := 1; i <= 5; i++ {
+		attempt := &common.MintAttempt{
+			Timestamp: uint64(i),
+			Batch:     uint64(i + 100),
+			Outcome:   "failed",
+			Error:     fmt.Sprintf("mint error %d", i),
+		}
+		err = store.WriteMintAttempt(attempt, 3)
+		require.Nil(err)
+	}
+
+	attempts, err = store.ListMintAttempts()
+	require.Nil(err)
+	require.Len(attempts, 3)
+	for i, a := range attempts {
+		require.Equal(uint64(i+3), a.Timestamp)
+		require.Equal(uint64(i+103), a.Batch)
+		require.Equal("failed", a.Outcome)
+		require.Equal(fmt.Sprintf("mint error %d", i+3), a.Error)
+	}
+}
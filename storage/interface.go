@@ -29,6 +29,7 @@ type Store interface {
 	ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error)
 	ReadSnapshotWithTransactionsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error)
 	ReadSnapshotsForNodeRound(nodeIdWithNetwork crypto.Hash, round uint64) ([]*common.SnapshotWithTopologicalOrder, error)
+	ReadLastSnapshotRound(nodeId crypto.Hash) (uint64, error)
 	ReadRound(hash crypto.Hash) (*common.Round, error)
 	ReadLink(from, to crypto.Hash) (uint64, error)
 	WriteSnapshot(*common.SnapshotWithTopologicalOrder, []crypto.Hash) error
@@ -49,11 +50,16 @@ type Store interface {
 	ListNodeWorks(cids []crypto.Hash, day uint32) (map[crypto.Hash][2]uint64, error)
 	ReadWorkOffset(nodeId crypto.Hash) (uint64, error)
 	WriteRoundWork(nodeId crypto.Hash, round uint64, snapshots []*common.SnapshotWork) error
+	WriteMintAttempt(attempt *common.MintAttempt, limit int) error
+	ListMintAttempts() ([]*common.MintAttempt, error)
+	ReadLastMintProposal() (uint64, error)
+	WriteLastMintProposal(batch uint64) error
 
 	ReadRoundSpaceCheckpoint(nodeId crypto.Hash) (uint64, uint64, error)
 	WriteRoundSpaceAndState(space *common.RoundSpace) error
 	ListAggregatedRoundSpaceCheckpoints(cids []crypto.Hash) (map[crypto.Hash]*common.RoundSpace, error)
 	ReadNodeRoundSpacesForBatch(nodeId crypto.Hash, batch uint64) ([]*common.RoundSpace, error)
+	ListNodeRoundSpaceBatches(nodeId crypto.Hash) ([]uint64, error)
 
 	RemoveGraphEntries(prefix string) (int, error)
 	ValidateGraphEntries(networkId crypto.Hash, depth uint64) (int, int, error)